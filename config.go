@@ -2,13 +2,19 @@ package config
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"reflect"
 	"strings"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cast"
 )
 
 // New return  a New Config
+// Config is safe for concurrent use, every change build a new ConfigMap
+// snapshot that replace the current one atomically
 func New() *Config {
 	c := &Config{}
 	//create a default ConfigMap
@@ -17,87 +23,310 @@ func New() *Config {
 	return c
 }
 
+// SetConfigMap set the base configurations, merged over the defaults and
+// overridden by the config files, env variables, flags and Set
 func (c *Config) SetConfigMap(cm ConfigMap) *Config {
-	c.ConfigMap = cm
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.base = cm
+	if err := c.build(); err != nil {
+		c.ConfigMap = cm
+	}
 	return c
 }
 
 func (c *Config) SetConfigImpl(impl Configuration) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.configImpl = impl
 	return c
 }
 
 // LoadConfig is a function to load the configurations in ConfigMap
+// the files given are remembered, so they can be re-read later by WatchConfig,
+// and a file loaded again replace its previous load with the last precedence.
+// the relative files are searched in the paths added with AddConfigPath, and
+// without files the one named by SetConfigName is loaded. the files not found
+// return an error wrapping ErrConfigNotFound, use LoadOptional for the files
+// that may not exist
 func (c *Config) LoadConfigs(configFiles ...string) (err error) {
-	// validate if required files exist to start reading the configs
+	return c.loadFiles(configFiles, false)
+}
+
+// LoadOptional is LoadConfigs for optional files, they are skipped if they don't
+// exist and loaded by WatchConfig once they are created. the files not found in
+// the config paths are expected in the first one
+//
+//	c.LoadOptional("local.yaml")
+func (c *Config) LoadOptional(configFiles ...string) error {
+	return c.loadFiles(configFiles, true)
+}
+
+// loadFiles search and load the files given, or the one named by SetConfigName
+func (c *Config) loadFiles(configFiles []string, optional bool) error {
+	c.mu.RLock()
+	paths, name := c.configPaths, c.configName
+	c.mu.RUnlock()
+	if len(configFiles) < 1 && name != "" {
+		configFile, ok := findConfigFile(paths, name)
+		if !ok {
+			if optional {
+				return nil
+			}
+			return fmt.Errorf("fail to load configs %s: %w", name, ErrConfigNotFound)
+		}
+		configFiles = []string{configFile}
+	}
+
+	sources := make([]configSource, 0, len(configFiles))
 	for _, configFile := range configFiles {
+		// validate if required files exist to start reading the configs
 		if configFile == "" {
 			return fmt.Errorf("configuration file should not be empty")
 		}
-
-		if _, err := os.Stat(configFile); err != nil {
-			fmt.Printf("no able to find file %s \n", configFile)
+		path, ok := searchFile(paths, configFile)
+		if !ok && !optional {
+			return fmt.Errorf("fail to load configs from file %s: %w", configFile, ErrConfigNotFound)
 		}
+		sources = append(sources, configSource{path: path, optional: optional})
 	}
+	return c.load(sources...)
+}
 
-	// set default values from the implementation
-	if c.configImpl != nil {
-		for key, val := range c.configImpl.SetDefaults() {
-			c.SetDefault(key, val)
+// LoadReader is a function to load the configurations from a io.Reader in ConfigMap,
+// the content is decoded with the decoder registered for the format given (json, yaml, toml...)
+func (c *Config) LoadReader(r io.Reader, format string) error {
+	if _, ok := getDecoder(format); !ok {
+		return fmt.Errorf("invalid extension type: %s", normalizeExt(format))
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("fail to read configs from reader: %w", err)
+	}
+	return c.load(configSource{format: format, content: content})
+}
+
+// LoadFS is a function to load the configurations from files in a fs.FS (like embed.FS) in ConfigMap
+func (c *Config) LoadFS(fsys fs.FS, paths ...string) error {
+	sources := make([]configSource, 0, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			return fmt.Errorf("configuration file should not be empty")
 		}
+		sources = append(sources, configSource{fsys: fsys, path: path})
 	}
+	return c.load(sources...)
+}
 
-	// load the configs from file
-	if err := c.getLocalConfigs(configFiles...); err != nil {
+// load read the sources given and build the configurations with them,
+// the sources are remembered, so they can be loaded again by WatchConfig
+func (c *Config) load(sources ...configSource) error {
+	layers, err := readSources(sources)
+	if err != nil {
 		return err
 	}
+	// SetDefaults is called without the lock, so it can read the configurations
+	c.mu.RLock()
+	impl := c.configImpl
+	c.mu.RUnlock()
+	nextImplDefaults := readImplDefaults(impl)
 
-	// merge the env Variables (replace the placeholders) if have values on EnvConfigMap
-	if len(c.EnvConfigMap) > 0 {
-		c.mergeEnvVariables()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	implDefaults := c.implDefaults
+	c.implDefaults = nextImplDefaults
+	prevLayers := c.layers
+	for _, l := range layers {
+		c.layers = replaceLayer(c.layers, l)
+	}
+	if err := c.build(); err != nil {
+		// keep the previous configurations
+		c.layers = prevLayers
+		c.implDefaults = implDefaults
+		return err
 	}
-
 	return nil
+}
 
+// replaceLayer return the layers given with the layer added as the last one,
+// removing the previous layer of the same source, so the sources loaded again
+// have the precedence of the last load and are not merged many times
+func replaceLayer(layers []layer, l layer) []layer {
+	out := make([]layer, 0, len(layers)+1)
+	for _, prev := range layers {
+		if !prev.source.equal(l.source) {
+			out = append(out, prev)
+		}
+	}
+	return append(out, l)
 }
 
-func (c *Config) getLocalConfigs(configFiles ...string) error {
-	for _, s := range configFiles {
-		if err := c.ConfigFileMerge(s); err != nil {
-			return fmt.Errorf("fail to load configs from file %s: %w", s, err)
+// readSources read and decode the configs from the sources given
+func readSources(sources []configSource) ([]layer, error) {
+	layers := make([]layer, 0, len(sources))
+	for _, source := range sources {
+		l, err := source.read()
+		if err != nil {
+			return nil, fmt.Errorf("fail to load configs from %s: %w", source, err)
 		}
+		layers = append(layers, l)
 	}
-	return nil
+	return layers, nil
 }
 
-// ConfigFileMerge read configs from file and merge the config into ConfigMap
-// if Key exist previosly in ConfigMap, the value will be overridden by the value from the file
-func (c *Config) ConfigFileMerge(s string) error {
-	if c.ConfigMap == nil {
-		c.ConfigMap = make(ConfigMap)
+// readImplDefaults return the default values from the Configuration implementation given
+func readImplDefaults(impl Configuration) ConfigMap {
+	if impl == nil {
+		return nil
+	}
+	implDefaults := impl.SetDefaults()
+	defaults := make(ConfigMap)
+	for _, key := range sortedKeys(implDefaults) {
+		keys := splitKey(key)
+		// if key don't exist we add it
+		if GetValue(defaults, keys) == nil {
+			SetValue(defaults, keys, implDefaults[key])
+		}
+	}
+	return defaults
+}
+
+// build merge all the layers of configurations and replace the ConfigMap
+// with the result, the caller must hold the lock. the precedence is
+// defaults < config map < config files < env variables < flags < Set
+func (c *Config) build() error {
+	// the values written directly in ConfigMap before the first build are kept as base
+	if !c.built {
+		c.built = true
+		if len(c.ConfigMap) > 0 {
+			c.base = MergeKeys(copyMap(c.ConfigMap), copyMap(c.base))
+		}
+	}
+	// the flags are read now, the reads build again when they change
+	flags := c.flagValues()
+	c.builtFlags = flags
+	cm := make(ConfigMap)
+	// origins has the source of the values set by each layer, the last one wins
+	origins := make(map[string]Origin)
+	// the layers are copied, so the merge never modify them
+	for _, m := range []ConfigMap{c.implDefaults, c.defaults} {
+		merged, moved, err := mergeKeysWith(cm, copyMap(m), c.mergeOptions)
+		if err != nil {
+			return fmt.Errorf("fail to merge default configs: %w", err)
+		}
+		cm = merged
+		addMergedOrigins(origins, m, moved, func(string) Origin { return Origin{Source: SourceDefault} })
+	}
+	merged, moved, err := mergeKeysWith(cm, copyMap(c.base), c.mergeOptions)
+	if err != nil {
+		return fmt.Errorf("fail to merge config map: %w", err)
+	}
+	cm = merged
+	addMergedOrigins(origins, c.base, moved, func(string) Origin { return Origin{Source: SourceConfigMap} })
+	for _, l := range c.layers {
+		merged, moved, err := mergeKeysWith(cm, copyMap(l.data), c.mergeOptions)
+		if err != nil {
+			return fmt.Errorf("fail to merge configs from %s: %w", l.source, err)
+		}
+		cm = merged
+		addMergedOrigins(origins, l.data, moved, func(key string) Origin { return l.source.origin(l.lines, key) })
+		for key, origin := range l.includeOrigins {
+			origins[movedKey(moved, key)] = origin
+		}
+	}
+
+	// merge the env Variables (replace the placeholders), without WithEnv
+	// the variables of the process are used
+	envVars := c.EnvConfigMap
+	if !c.withEnv && len(envVars) == 0 {
+		envVars = environ(nil)
 	}
-	config, err := ReadFile(s)
+	used := make(map[string][]string)
+	if cm, err = mergeEnvVar(cm, envVars, c.envTypes, used, nil); err != nil {
+		return fmt.Errorf("fail to replace env placeholders: %w", err)
+	}
+	for key, names := range used {
+		origin := origins[key]
+		origin.Env = strings.Join(names, ", ")
+		origins[key] = origin
+	}
+
+	// override the keys bound to env variables
+	cm, err = c.mergeEnvOverrides(cm, origins)
 	if err != nil {
 		return err
 	}
-	c.ConfigMap = MergeKeys(c.ConfigMap, config)
+
+	cm = applyOverrides(cm, flags, origins, func(key string) Origin { return Origin{Source: SourceFlag, Path: key} })
+	cm = applyOverrides(cm, c.overrides, origins, func(string) Origin { return Origin{Source: SourceSet} })
+
+	// only the keys in the final configs are kept
+	keys := Flatten(cm)
+	for key := range origins {
+		if _, ok := keys[key]; !ok {
+			delete(origins, key)
+		}
+	}
+	c.ConfigMap = cm
+	c.origins = origins
 	return nil
 }
 
+// ConfigFileMerge read configs from file and merge the config into ConfigMap
+// if Key exist previosly in ConfigMap, the value will be overridden by the value from the file
+func (c *Config) ConfigFileMerge(s string) error {
+	return c.load(configSource{path: s})
+}
+
+// Snapshot return a copy of the current ConfigMap
+func (c *Config) Snapshot() ConfigMap {
+	c.syncFlags()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return copyMap(c.ConfigMap)
+}
+
 // Get return value from given key, and return empty string if key don't exist
 // key can be passed in `dot-notation`
 func (c *Config) Get(k string) interface{} {
-	return GetValue(c.ConfigMap, strings.Split(k, "."))
+	c.syncFlags()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return GetValue(c.ConfigMap, splitKey(k))
 }
 
 func (c *Config) getEnv(k string) interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return GetValue(c.EnvConfigMap, []string{k})
 }
 
-// Set add or update value from given key
-// key can be passed in `dot-notation`
+// Set add or update value from given key, values set have
+// precedence over any other source of configurations
+// key can be passed in `dot-notation`, the appended items (`servers[]`)
+// are set by the index they get, so each Set append a new item
 func (c *Config) Set(k string, v interface{}) {
-	SetValue(c.ConfigMap, strings.Split(k, "."), v)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := buildKey(resolveAppends(c.ConfigMap, splitKey(k)))
+	c.overrides = setOverride(c.overrides, key, copyValue(v))
+	if err := c.build(); err != nil {
+		// keep the previous configurations, the error is returned on the next load
+		c.ConfigMap = SetValue(copyMap(c.ConfigMap), splitKey(k), v)
+	}
+}
+
+// Unset remove the key given in `dot-notation` from the configurations, whatever
+// the source of its value, until the key is set again with Set. the items of
+// the lists are removed by their index, like `servers.0`
+func (c *Config) Unset(k string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.overrides = setOverride(c.overrides, normalizeKey(k), Delete)
+	if err := c.build(); err != nil {
+		// keep the previous configurations, the error is returned on the next load
+		c.ConfigMap = ConfigMap(deleteValue(copyMap(c.ConfigMap), splitKey(k)))
+	}
 }
 
 func (c *Config) isSet(k string) bool {
@@ -110,24 +339,125 @@ func (c *Config) isSetEnv(k string) bool {
 	return value != nil
 }
 
+// SetDefault add the default value from given key, used only
+// if the key is not set by any other source of configurations
+// key can be passed in `dot-notation`
 func (c *Config) SetDefault(key string, val interface{}) {
-	// if key don't exist we add it
-	if !c.isSet(key) {
-		c.Set(key, val)
+	c.setDefaults(map[string]interface{}{key: val})
+}
+
+// setDefaults add the default values of the keys given, in `dot-notation`,
+// and build the configurations once
+func (c *Config) setDefaults(values map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.defaults == nil {
+		c.defaults = make(ConfigMap)
+	}
+	keys := sortedKeys(values)
+	for _, key := range keys {
+		SetValue(c.defaults, splitKey(key), copyValue(values[key]))
+	}
+	if err := c.build(); err != nil {
+		// keep the previous configurations, the error is returned on the next load
+		cm := copyMap(c.ConfigMap)
+		for _, key := range keys {
+			if GetValue(cm, splitKey(key)) == nil {
+				SetValue(cm, splitKey(key), values[key])
+			}
+		}
+		c.ConfigMap = cm
 	}
 }
 
 // WithEnv Load env variables and add into ConfigMap
 func (c *Config) WithEnv(envs ...string) *Config {
-	if c.EnvConfigMap == nil {
-		c.EnvConfigMap = make(ConfigMap)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	envConfigMap := copyMap(c.EnvConfigMap)
+	for name, value := range environ(envs) {
+		envConfigMap[name] = value
 	}
+	c.EnvConfigMap = envConfigMap
+	c.withEnv = true
+	// if the build fails, the env variables are applied on the next load
+	_ = c.build()
+	return c
+}
+
+// environ return the env variables of the process named as the names given, or all
+// of them if no names are given
+func environ(names []string) ConfigMap {
+	envs := make(ConfigMap)
 	for _, v := range os.Environ() {
 		env := strings.SplitN(v, "=", 2)
-		if canSave(envs, env[0]) {
-			c.EnvConfigMap[env[0]] = env[1]
+		if canSave(names, env[0]) {
+			envs[env[0]] = env[1]
 		}
 	}
+	return envs
+}
+
+// WithTypedPlaceholders parse the values of the substituted env placeholders into their
+// natural YAML scalar type (int, float, bool or null), so `port: ${APP_PORT}` is an int.
+// only values with placeholders are parsed, values declared with
+// WithPlaceholderTypes are converted to the type declared instead
+func (c *Config) WithTypedPlaceholders() *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.envTypes == nil {
+		c.envTypes = &placeholderTypes{}
+	}
+	c.envTypes.natural = true
+	return c
+}
+
+// WithPlaceholderTypes declare the type the values of the substituted env placeholders
+// are converted to, the keys are in `dot-notation`. loading the configs fails
+// if a value can't be converted to the type declared
+func (c *Config) WithPlaceholderTypes(types map[string]ValueType) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.envTypes == nil {
+		c.envTypes = &placeholderTypes{}
+	}
+	if c.envTypes.keys == nil {
+		c.envTypes.keys = make(map[string]ValueType)
+	}
+	for key, valueType := range types {
+		c.envTypes.keys[normalizeKey(key)] = valueType
+	}
+	return c
+}
+
+// WithValidation check the structs decoded by Unmarshal, UnmarshalKey
+// and UnmarshalStrict with Validate, the validation is disabled by default
+func (c *Config) WithValidation() *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validation = true
+	return c
+}
+
+// WithMergeOptions set how the lists of the config files are merged with the lists
+// of the previous sources, by default they are replaced. loading the configs
+// fails with a *MergeConflictError if a value can't be merged
+//
+//	c.WithMergeOptions(config.MergeOptions{
+//		Keys: map[string]config.MergeRule{
+//			"plugins": {Strategy: config.MergeAppendUnique},
+//			"servers": {Strategy: config.MergeByKey, ItemKey: "name"},
+//		},
+//	})
+func (c *Config) WithMergeOptions(opts MergeOptions) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make(map[string]MergeRule, len(opts.Keys))
+	for key, rule := range opts.Keys {
+		keys[key] = rule
+	}
+	opts.Keys = keys
+	c.mergeOptions = opts
 	return c
 }
 
@@ -144,12 +474,61 @@ func canSave(e []string, k string) bool {
 }
 
 // Unmarshal function convert a ConfigMap type into a struct
-// using mapStructure Decoder
+// using mapStructure Decoder with the decode hooks, the keys not set take the values of the
+// `default` struct tags, then the struct is checked with Validate if WithValidation is set
 func (c *Config) Unmarshal(s any) error {
-	if err := mapStructureDecoder(c.ConfigMap, &s); err != nil {
-		return fmt.Errorf("unable to unmarshal configurations: %w", err)
+	return c.unmarshal("", s, false)
+}
+
+// UnmarshalKey function convert the configurations of the key given in `dot-notation`
+// into a struct, same as Unmarshal, like `services.login` into a LoginService struct.
+// an error is returned if the key is not set or its value is not a map
+func (c *Config) UnmarshalKey(key string, s any) error {
+	return c.unmarshal(key, s, false)
+}
+
+// unmarshal decode the configurations of the key into the struct given, all of them
+// if the key is empty. in strict mode the keys not used and the fields not set
+// are returned as a *StrictError
+func (c *Config) unmarshal(key string, s any, strict bool) error {
+	errPrefix := "unable to unmarshal configurations"
+	c.syncFlags()
+	c.mu.RLock()
+	val := interface{}(c.ConfigMap)
+	if key != "" {
+		errPrefix = "unable to unmarshal key " + key
+		val = GetValue(c.ConfigMap, splitKey(key))
 	}
-	return nil
+	hook := c.decodeHook()
+	validation := c.validation
+	c.mu.RUnlock()
+	if val == nil {
+		return fmt.Errorf("%s: %w", errPrefix, ErrKeyNotFound)
+	}
+	m, isMap := asMap(val)
+	if !isMap {
+		return fmt.Errorf("%s: value of type %T is not a map", errPrefix, val)
+	}
+	cm, err := applyTagDefaults(copyMap(m), reflect.TypeOf(s), key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	var metadata *mapstructure.Metadata
+	if strict {
+		metadata = &mapstructure.Metadata{}
+	}
+	if err := mapStructureDecoder(cm, &s, metadata, hook); err != nil {
+		return fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	if strict {
+		if err := newStrictError(metadata); err != nil {
+			return fmt.Errorf("%s: %w", errPrefix, err)
+		}
+	}
+	if !validation {
+		return nil
+	}
+	return validate(s, key)
 }
 
 // MustString returns the value associated with the key as a string or a default value if empty string.
@@ -221,9 +600,3 @@ func (c *Config) MustBool(key string, must bool) bool {
 	}
 	return val
 }
-
-// mergeEnvVariables replace placeholders on config files
-func (c *Config) mergeEnvVariables() {
-	mergeENV := MergeEnvVar(c.ConfigMap, c.EnvConfigMap)
-	c.ConfigMap = mergeENV
-}
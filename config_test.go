@@ -5,7 +5,9 @@ import (
 	"math/rand"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
@@ -34,7 +36,7 @@ func TestConfig(t *testing.T) {
 		configMap := make(ConfigMap)
 		envConfigMap := make(ConfigMap)
 		config := New().SetConfigImpl(mock).SetConfigMap(configMap).WithEnv()
-		var want = &Config{configMap, envConfigMap, mock}
+		var want = &Config{ConfigMap: configMap, EnvConfigMap: envConfigMap, configImpl: mock, base: configMap, origins: map[string]Origin{}}
 		want.WithEnv()
 		areEqual := assert.ObjectsAreEqual(config, want)
 		assert.True(t, areEqual)
@@ -192,6 +194,299 @@ func TestMustFunctions(t *testing.T) {
 	})
 }
 
+func TestLoadReaderAndFS(t *testing.T) {
+	t.Run("test LoadReader", func(t *testing.T) {
+		mock := &MockConfig{}
+		config := New().SetConfigImpl(mock)
+		assert.NoError(t, config.LoadReader(strings.NewReader("application:\n  port: 3001\n"), "yaml"))
+		assert.NoError(t, config.Unmarshal(mock))
+		assert.Equal(t, 3001, mock.App.Port)
+		assert.Equal(t, 2, config.Get("this.is.a.very.nested.config.with.second"))
+	})
+
+	t.Run("test LoadReader with invalid format", func(t *testing.T) {
+		config := New()
+		assert.ErrorContains(t, config.LoadReader(strings.NewReader("a = 1"), "ini"), "invalid extension type")
+	})
+
+	t.Run("test LoadReader with invalid content", func(t *testing.T) {
+		config := New()
+		assert.ErrorContains(t, config.LoadReader(strings.NewReader(`{"a": `), "json"), "fail to load configs from reader")
+	})
+
+	t.Run("test LoadFS merged with files and env placeholders", func(t *testing.T) {
+		os.Setenv("LOAD_FS_LOG_LEVEL", "debug")
+		defer os.Unsetenv("LOAD_FS_LOG_LEVEL")
+		fsys := fstest.MapFS{
+			"defaults/config.yaml": {Data: []byte("application:\n  port: 3001\nlogger:\n  log_level: ${LOAD_FS_LOG_LEVEL}\n")},
+		}
+		dir := t.TempDir()
+		file := dir + "/config.json"
+		assert.NoError(t, os.WriteFile(file, []byte(`{"application": {"port": 4000}}`), 0644))
+
+		mock := &MockConfig{}
+		config := New().WithEnv()
+		assert.NoError(t, config.LoadFS(fsys, "defaults/config.yaml"))
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.NoError(t, config.Unmarshal(mock))
+		assert.Equal(t, 4000, mock.App.Port)
+		assert.Equal(t, "debug", mock.Logger.Level)
+	})
+
+	t.Run("test LoadFS with missing file", func(t *testing.T) {
+		config := New()
+		assert.ErrorContains(t, config.LoadFS(fstest.MapFS{}, "config.yaml"), "fail to load configs from file config.yaml")
+		assert.ErrorContains(t, config.LoadFS(fstest.MapFS{}, ""), "configuration file")
+	})
+}
+
+func TestUnmarshalKey(t *testing.T) {
+	config := New()
+	config.SetConfigMap(ConfigMap{
+		"services": ConfigMap{
+			"login": ConfigMap{"log_level": "debug", "log_output_to": "stdout"},
+			"name":  "login",
+		},
+	})
+
+	t.Run("test UnmarshalKey decode the subtree", func(t *testing.T) {
+		logger := &MockLoggerConfig{}
+		assert.NoError(t, config.UnmarshalKey("services.login", logger))
+		assert.Equal(t, &MockLoggerConfig{Level: "debug", LogOutput: "stdout"}, logger)
+	})
+
+	t.Run("test UnmarshalKey with missing key", func(t *testing.T) {
+		err := config.UnmarshalKey("services.auth", &MockLoggerConfig{})
+		assert.EqualError(t, err, "unable to unmarshal key services.auth: key not found")
+	})
+
+	t.Run("test UnmarshalKey with a value that is not a map", func(t *testing.T) {
+		err := config.UnmarshalKey("services.name", &MockLoggerConfig{})
+		assert.EqualError(t, err, "unable to unmarshal key services.name: value of type string is not a map")
+	})
+
+	t.Run("test UnmarshalKey validate with the full keys", func(t *testing.T) {
+		config.WithValidation()
+		err := config.UnmarshalKey("services.login", &struct {
+			Level string `mapstructure:"log_level" validate:"oneof=info warn"`
+		}{})
+		assert.EqualError(t, err, "invalid configurations:\nservices.login.log_level: should be one of [info warn]")
+	})
+}
+
+func TestListKeys(t *testing.T) {
+	os.Setenv("LIST_KEYS_SERVERS_1_PORT", "9090")
+	defer os.Unsetenv("LIST_KEYS_SERVERS_1_PORT")
+	config := New().WithEnvPrefix("LIST_KEYS")
+	content := "servers:\n  - host: a\n    port: 80\n  - host: b\n    port: 81\n"
+	assert.NoError(t, config.LoadReader(strings.NewReader(content), "yaml"))
+
+	t.Run("test Get with indexes", func(t *testing.T) {
+		assert.Equal(t, "a", config.Get("servers.0.host"))
+		assert.Equal(t, "b", config.Get("servers[1].host"))
+		assert.Equal(t, "9090", config.Get("servers[1].port"))
+		assert.Nil(t, config.Get("servers.2.host"))
+	})
+
+	t.Run("test Set with indexes", func(t *testing.T) {
+		config.Set("servers[0].port", 8080)
+		config.Set("servers[].host", "c")
+		config.Set("tags[]", "web")
+		assert.Equal(t, 8080, config.Get("servers.0.port"))
+		assert.Equal(t, "c", config.Get("servers.2.host"))
+		assert.Equal(t, []interface{}{"web"}, config.Get("tags"))
+		origin, ok := config.Origin("servers.2.host")
+		assert.True(t, ok)
+		assert.Equal(t, SourceSet, origin.Source)
+		origin, _ = config.Origin("servers.1.host")
+		assert.Equal(t, Origin{Source: SourceReader, Path: "yaml"}, origin)
+	})
+
+	t.Run("test Set append each time", func(t *testing.T) {
+		config := New()
+		config.Set("servers[]", "a")
+		config.Set("servers[]", "b")
+		config.Set("servers.-", "c")
+		assert.Equal(t, []interface{}{"a", "b", "c"}, config.Get("servers"))
+		config.Set("servers[1]", "d")
+		assert.Equal(t, []interface{}{"a", "d", "c"}, config.Get("servers"))
+
+		// the keys set again are applied after their nested keys
+		config.Set("app", map[string]interface{}{"host": "a"})
+		config.Set("app.port", 80)
+		config.Set("app", map[string]interface{}{"host": "b"})
+		assert.Equal(t, map[string]interface{}{"host": "b"}, config.Get("app"))
+	})
+}
+
+func TestQuotedKeys(t *testing.T) {
+	config := New()
+	content := "hosts:\n  example.com:\n    port: 443\n"
+	assert.NoError(t, config.LoadReader(strings.NewReader(content), "yaml"))
+
+	assert.Equal(t, 443, config.Get(`hosts."example.com".port`))
+	assert.Equal(t, 443, config.Get(`hosts.example\.com.port`))
+	assert.Nil(t, config.Get("hosts.example.com.port"))
+
+	config.Set(`hosts."example.com".port`, 8443)
+	config.SetDefault(`hosts."local.dev".port`, 80)
+	assert.Equal(t, 8443, config.Get(`hosts.example\.com.port`))
+	assert.Equal(t, 80, config.Get(`hosts."local.dev".port`))
+
+	origin, ok := config.Origin(`hosts.example\.com.port`)
+	assert.True(t, ok)
+	assert.Equal(t, SourceSet, origin.Source)
+	assert.Contains(t, config.Explain(), `hosts."local.dev".port: default`)
+}
+
+func TestUnset(t *testing.T) {
+	config := New()
+	config.SetDefault("app.host", "localhost")
+	content := "app:\n  port: 3001\nservers:\n  - host: a\n  - host: b\n"
+	assert.NoError(t, config.LoadReader(strings.NewReader(content), "yaml"))
+
+	config.Unset("app.host")
+	config.Unset("servers[0]")
+	_, ok := config.Lookup("app.host")
+	assert.False(t, ok)
+	assert.Equal(t, 3001, config.Get("app.port"))
+	assert.Equal(t, []interface{}{ConfigMap{"host": "b"}}, config.Get("servers"))
+	_, ok = config.Origin("app.host")
+	assert.False(t, ok)
+
+	// the keys unset are removed from the configs loaded later
+	assert.NoError(t, config.LoadReader(strings.NewReader("app:\n  host: 0.0.0.0\n"), "yaml"))
+	assert.Nil(t, config.Get("app.host"))
+
+	config.Set("app.host", "127.0.0.1")
+	assert.Equal(t, "127.0.0.1", config.Get("app.host"))
+}
+
+func TestDeleteKeys(t *testing.T) {
+	dir := t.TempDir()
+	base := writeTempFile(t, dir, "base.yaml", `
+app:
+  host: localhost
+  port: 3001
+  debug: true
+servers:
+  - host: a
+    port: 80
+`)
+	local := writeTempFile(t, dir, "local.yaml", `
+app:
+  host: !delete
+  debug: ~
+servers:
+  - host: b
+    port: !delete
+`)
+	config := New()
+	assert.NoError(t, config.LoadConfigs(base))
+	assert.NoError(t, config.ConfigFileMerge(local))
+	_, ok := config.Lookup("app.host")
+	assert.False(t, ok)
+	val, ok := config.Lookup("app.debug")
+	assert.True(t, ok)
+	assert.Nil(t, val)
+	assert.Equal(t, []interface{}{ConfigMap{"host": "b"}}, config.Get("servers"))
+
+	config = New().WithMergeOptions(MergeOptions{DeleteNulls: true})
+	assert.NoError(t, config.LoadConfigs(base, local))
+	_, ok = config.Lookup("app.debug")
+	assert.False(t, ok)
+	assert.Equal(t, ConfigMap{"app": map[string]interface{}{"port": 3001}, "servers": []interface{}{ConfigMap{"host": "b"}}}, config.Snapshot())
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	t.Run("test Get and Set from many goroutines", func(t *testing.T) {
+		dir := t.TempDir()
+		file := dir + "/config.yaml"
+		assert.NoError(t, os.WriteFile(file, []byte("application:\n  port: 3001\n"), 0644))
+
+		config := New().SetConfigImpl(&MockConfig{})
+		assert.NoError(t, config.LoadConfigs(file))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(4)
+			go func(i int) {
+				defer wg.Done()
+				config.Set(fmt.Sprintf("workers.w%d", i), i)
+			}(i)
+			go func() {
+				defer wg.Done()
+				config.SetDefault("application.host", "127.0.0.1")
+			}()
+			go func() {
+				defer wg.Done()
+				assert.NotNil(t, config.Get("application.port"))
+				assert.Equal(t, 3001, config.MustInt("application.port", 0))
+			}()
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, config.ConfigFileMerge(file))
+				mock := &MockConfig{}
+				assert.NoError(t, config.Unmarshal(mock))
+				assert.Equal(t, 3001, mock.App.Port)
+			}()
+		}
+		wg.Wait()
+
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, i, config.Get(fmt.Sprintf("workers.w%d", i)))
+		}
+		assert.Equal(t, "127.0.0.1", config.Get("application.host"))
+		// the file loaded many times is merged once
+		assert.Len(t, config.layers, 1)
+	})
+
+	t.Run("test values written in ConfigMap before loading are kept", func(t *testing.T) {
+		file := writeTempFile(t, t.TempDir(), "config.yaml", "app:\n  port: 3001\n")
+		config := New()
+		config.ConfigMap["name"] = "service"
+		config.ConfigMap["app"] = ConfigMap{"port": 80, "host": "localhost"}
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.Equal(t, "service", config.Get("name"))
+		assert.Equal(t, "localhost", config.Get("app.host"))
+		assert.Equal(t, 3001, config.Get("app.port"))
+		origin, _ := config.Origin("name")
+		assert.Equal(t, SourceConfigMap, origin.Source)
+	})
+
+	t.Run("test sources loaded again replace their layer", func(t *testing.T) {
+		dir := t.TempDir()
+		a := writeTempFile(t, dir, "a.yaml", "port: 1\n")
+		b := writeTempFile(t, dir, "b.yaml", "port: 2\n")
+		config := New()
+		assert.NoError(t, config.LoadConfigs(a, b))
+		assert.Equal(t, 2, config.Get("port"))
+		assert.NoError(t, config.LoadConfigs(a))
+		assert.Equal(t, 1, config.Get("port"))
+		assert.NoError(t, config.LoadReader(strings.NewReader("port: 3\n"), "yaml"))
+		assert.NoError(t, config.LoadReader(strings.NewReader("port: 3\n"), "yaml"))
+		assert.Equal(t, 3, config.Get("port"))
+		assert.Len(t, config.layers, 3)
+	})
+
+	t.Run("test Snapshot is not affected by later changes", func(t *testing.T) {
+		config := New()
+		config.Set("app.port", 3001)
+		snapshot := config.Snapshot()
+		config.Set("app.port", 4000)
+		assert.Equal(t, 3001, GetValue(snapshot, []string{"app", "port"}))
+		assert.Equal(t, 4000, config.Get("app.port"))
+	})
+
+	t.Run("test SetDefault don't override existing values", func(t *testing.T) {
+		config := New()
+		config.Set("app.port", 3001)
+		config.SetDefault("app.port", 4000)
+		config.SetDefault("app", "scalar")
+		assert.Equal(t, 3001, config.Get("app.port"))
+	})
+}
+
 func (mc *MockConfig) SetDefaults() ConfigMap {
 	defaults := make(ConfigMap)
 	defaults["this.is.a.very.nested.config"] = true
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = make(map[string]DecoderFunc)
+)
+
+func init() {
+	RegisterDecoder("json", decodeWith(jsonDecode))
+	RegisterDecoder("yaml", decodeWith(yamlDecode))
+	RegisterDecoder("yml", decodeWith(yamlDecode))
+	RegisterDecoder("toml", decodeWith(tomlDecode))
+}
+
+// RegisterDecoder add a decoder for the file extension given, so ReadFile
+// can read new file formats. extensions are case-insensitive and can be given
+// with or without the leading dot, registering an extension again replace the
+// previous decoder and a nil decoder remove it
+func RegisterDecoder(ext string, fn DecoderFunc) {
+	ext = normalizeExt(ext)
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	if fn == nil {
+		delete(decoders, ext)
+		return
+	}
+	decoders[ext] = fn
+}
+
+// getDecoder return the decoder registered for the extension given
+func getDecoder(ext string) (DecoderFunc, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	fn, ok := decoders[normalizeExt(ext)]
+	return fn, ok
+}
+
+// decoderExts return the extensions with a decoder registered, sorted
+func decoderExts() []string {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	exts := make([]string, 0, len(decoders))
+	for ext := range decoders {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+func normalizeExt(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+// decodeWith wrap the internal decoders into a DecoderFunc
+func decodeWith(fn func([]byte, *ConfigMap) error) DecoderFunc {
+	return func(content []byte) (ConfigMap, error) {
+		dataMap := make(ConfigMap)
+		if err := fn(content, &dataMap); err != nil {
+			return nil, err
+		}
+		return dataMap, nil
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterDecoder(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTempFile(t, dir, "test.properties", "a.b=c\nd=e\n")
+
+	if _, err := ReadFile(path); err == nil || !strings.Contains(err.Error(), "invalid extension type") {
+		t.Fatalf("expected invalid extension error before registering, got: %v", err)
+	}
+
+	RegisterDecoder(".Properties", func(content []byte) (ConfigMap, error) {
+		m := make(ConfigMap)
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) != 2 {
+				return nil, errors.New("invalid line")
+			}
+			SetValue(m, strings.Split(kv[0], "."), kv[1])
+		}
+		return m, nil
+	})
+	defer RegisterDecoder("properties", nil)
+
+	m, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile returned unexpected error: %v", err)
+	}
+	if v := GetValue(m, []string{"a", "b"}); v != "c" {
+		t.Fatalf("expected a.b=c, got %#v", v)
+	}
+
+	RegisterDecoder("properties", nil)
+	if _, err := ReadFile(path); err == nil {
+		t.Fatalf("expected error after removing the decoder, got nil")
+	}
+}
+
+func TestReadFileExtensionCaseInsensitive(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTempFile(t, dir, "test.JSON", `{"a":"b"}`)
+
+	m, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile(.JSON) returned unexpected error: %v", err)
+	}
+	if v, _ := m["a"].(string); v != "b" {
+		t.Fatalf("expected a=b, got %#v", m["a"])
+	}
+}
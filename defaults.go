@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// SetDefaultsFrom set the defaults declared with the `default` struct tag of the
+// struct given, walking nested structs and pointers, so the key paths are only
+// declared in the mapstructure tags:
+//
+//	type App struct {
+//		Host    string        `mapstructure:"host" default:"127.0.0.1"`
+//		Timeout time.Duration `mapstructure:"timeout" default:"5s"`
+//		Tags    []string      `mapstructure:"tags" default:"api,web"`
+//	}
+//
+// the defaults of structs inside slices and maps can't be keys, they are
+// applied to every item by Unmarshal
+func (c *Config) SetDefaultsFrom(s any) error {
+	defaults, err := applyTagDefaults(make(ConfigMap), reflect.TypeOf(s), "")
+	if err != nil {
+		return err
+	}
+	// the lists are set as a whole, and the configurations are built once
+	c.setDefaults(flatten(defaults, nil, make(map[string]interface{}), false))
+	return nil
+}
+
+// applyTagDefaults set the values of the `default` struct tags of the type given
+// into the keys not set in the map given, including the items of slices and maps
+// of structs. the map is modified, so a copy should be given
+func applyTagDefaults(cm ConfigMap, typ reflect.Type, prefix string) (ConfigMap, error) {
+	typ = derefType(typ)
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return cm, nil
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, squash := fieldKey(field)
+		if name == "-" {
+			continue
+		}
+		if squash {
+			if _, err := applyTagDefaults(cm, field.Type, prefix); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		key := lookupKey(cm, name)
+		fieldType := derefType(field.Type)
+		val, isSet := cm[key]
+		if tag, ok := field.Tag.Lookup("default"); ok && (!isSet || val == nil) {
+			converted, err := convertDefault(tag, fieldType)
+			if err != nil {
+				return nil, fmt.Errorf("invalid default for key %s: %w", joinKey(prefix, name), err)
+			}
+			cm[key] = converted
+			continue
+		}
+		if err := applyNestedDefaults(cm, key, fieldType, joinKey(prefix, name)); err != nil {
+			return nil, err
+		}
+	}
+	return cm, nil
+}
+
+// applyNestedDefaults apply the defaults of the nested structs of the key given
+func applyNestedDefaults(cm ConfigMap, key string, typ reflect.Type, path string) error {
+	switch typ.Kind() {
+	case reflect.Struct:
+		nested, isMap := asMap(cm[key])
+		if !isMap {
+			if _, isSet := cm[key]; isSet && cm[key] != nil {
+				// not a map, let the decoder report it
+				return nil
+			}
+			nested = make(map[string]interface{})
+		}
+		applied, err := applyTagDefaults(ConfigMap(nested), typ, path)
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			cm[key] = applied
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := cm[key].([]interface{})
+		if !ok || derefType(typ.Elem()).Kind() != reflect.Struct {
+			return nil
+		}
+		for i, item := range items {
+			itemMap, isMap := asMap(item)
+			if !isMap {
+				continue
+			}
+			if _, err := applyTagDefaults(ConfigMap(itemMap), typ.Elem(), fmt.Sprintf("%s.%d", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		items, isMap := asMap(cm[key])
+		if !isMap || derefType(typ.Elem()).Kind() != reflect.Struct {
+			return nil
+		}
+		for _, itemKey := range sortedKeys(items) {
+			itemMap, isMap := asMap(items[itemKey])
+			if !isMap {
+				continue
+			}
+			if _, err := applyTagDefaults(ConfigMap(itemMap), typ.Elem(), joinKey(path, itemKey)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// convertDefault convert the value of the default tag to the type of the field,
+// slices are declared as values separated by commas
+func convertDefault(value string, typ reflect.Type) (interface{}, error) {
+	if typ == durationType {
+		return time.ParseDuration(value)
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return cast.ToBoolE(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return cast.ToIntE(value)
+	case reflect.Int64:
+		return cast.ToInt64E(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return cast.ToUintE(value)
+	case reflect.Uint64:
+		return cast.ToUint64E(value)
+	case reflect.Float32, reflect.Float64:
+		return cast.ToFloat64E(value)
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, 0)
+		if value == "" {
+			return list, nil
+		}
+		for _, item := range strings.Split(value, ",") {
+			converted, err := convertDefault(strings.TrimSpace(item), derefType(typ.Elem()))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return list, nil
+	}
+	return value, nil
+}
+
+// lookupKey return the key of the map matching the name given, the match is
+// case insensitive as in the decoder. the name is returned if there is no match
+func lookupKey(cm ConfigMap, name string) string {
+	if _, ok := cm[name]; ok {
+		return name
+	}
+	for _, key := range sortedKeys(cm) {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+func derefType(typ reflect.Type) reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type defaultsLogin struct {
+	Host string `mapstructure:"host" default:"127.0.0.1"`
+	Port int    `mapstructure:"port" default:"3002"`
+	User string `mapstructure:"user"`
+}
+
+type defaultsBackend struct {
+	Host   string `mapstructure:"host"`
+	Weight int    `mapstructure:"weight" default:"1"`
+}
+
+type defaultsConfig struct {
+	Debug    bool                       `mapstructure:"debug" default:"true"`
+	Timeout  time.Duration              `mapstructure:"timeout" default:"5s"`
+	Tags     []string                   `mapstructure:"tags" default:"api,web"`
+	Login    *defaultsLogin             `mapstructure:"login"`
+	Backends []defaultsBackend          `mapstructure:"backends"`
+	Services map[string]defaultsBackend `mapstructure:"services"`
+}
+
+func TestUnmarshalTagDefaults(t *testing.T) {
+	config := New()
+	config.SetConfigMap(ConfigMap{
+		"login": ConfigMap{"port": 4000},
+		"backends": []interface{}{
+			ConfigMap{"host": "a"},
+			ConfigMap{"host": "b", "weight": 3},
+		},
+		"services": ConfigMap{"auth": ConfigMap{"host": "c"}},
+	})
+	cfg := &defaultsConfig{}
+	assert.NoError(t, config.Unmarshal(cfg))
+	assert.True(t, cfg.Debug)
+	assert.Equal(t, 5*time.Second, cfg.Timeout)
+	assert.Equal(t, []string{"api", "web"}, cfg.Tags)
+	assert.Equal(t, &defaultsLogin{Host: "127.0.0.1", Port: 4000}, cfg.Login)
+	assert.Equal(t, []defaultsBackend{{Host: "a", Weight: 1}, {Host: "b", Weight: 3}}, cfg.Backends)
+	assert.Equal(t, map[string]defaultsBackend{"auth": {Host: "c", Weight: 1}}, cfg.Services)
+	// the configurations are not modified by the tag defaults
+	assert.Nil(t, config.Get("debug"))
+}
+
+func TestSetDefaultsFrom(t *testing.T) {
+	config := New()
+	config.SetConfigMap(ConfigMap{"login": ConfigMap{"port": 4000}})
+	assert.NoError(t, config.SetDefaultsFrom(&defaultsConfig{}))
+
+	assert.Equal(t, true, config.Get("debug"))
+	assert.Equal(t, 5*time.Second, config.Get("timeout"))
+	assert.Equal(t, []interface{}{"api", "web"}, config.Get("tags"))
+	assert.Equal(t, "127.0.0.1", config.Get("login.host"))
+	assert.Equal(t, 4000, config.Get("login.port"))
+	assert.Nil(t, config.Get("backends"))
+
+	origin, ok := config.Origin("login.host")
+	assert.True(t, ok)
+	assert.Equal(t, SourceDefault, origin.Source)
+
+	err := config.SetDefaultsFrom(&struct {
+		Port int `mapstructure:"port" default:"http"`
+	}{})
+	assert.ErrorContains(t, err, "invalid default for key port")
+}
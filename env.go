@@ -0,0 +1,359 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v3"
+)
+
+// expandPlaceholders replace the `${NAME}` placeholders inside the string given with
+// the values from envVars, supporting shell-style modifiers:
+//
+//	${NAME}          value of NAME or empty string if is not set
+//	${NAME:-default} default if NAME is not set or empty, ${NAME-default} only if not set
+//	${NAME:?message} error with message if NAME is not set or empty, ${NAME?message} only if not set
+//	$${NAME}         escaped, the literal text ${NAME}
+func expandPlaceholders(s string, envVars ConfigMap) (string, error) {
+	expanded, _, err := expandPlaceholdersNames(s, envVars)
+	return expanded, err
+}
+
+// expandPlaceholdersNames is expandPlaceholders returning the names of the env variables used too
+func expandPlaceholdersNames(s string, envVars ConfigMap) (string, []string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil, nil
+	}
+	var (
+		b     strings.Builder
+		names []string
+	)
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			b.WriteString("${")
+			i += len("$${")
+		case strings.HasPrefix(s[i:], "${"):
+			end := closingBrace(s, i+len("${"))
+			if end < 0 {
+				// unterminated placeholder, keep the rest as it is
+				b.WriteString(s[i:])
+				return b.String(), names, nil
+			}
+			val, used, err := expandPlaceholder(s[i+len("${"):end], envVars)
+			if err != nil {
+				return "", nil, err
+			}
+			b.WriteString(val)
+			names = append(names, used...)
+			i = end + 1
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String(), names, nil
+}
+
+// closingBrace return the position of the brace closing the placeholder
+// started before start, taking care of the nested placeholders
+func closingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// expandPlaceholder resolve the expression inside a placeholder,
+// returning the names of the env variables used
+func expandPlaceholder(expr string, envVars ConfigMap) (string, []string, error) {
+	name, op, arg := splitPlaceholder(expr)
+	val, isSet := envVars[name]
+	value := ""
+	if isSet {
+		value = cast.ToString(val)
+	}
+	// modifiers with ':' also apply when the variable is empty
+	useArg := !isSet || (strings.HasPrefix(op, ":") && value == "")
+	switch strings.TrimPrefix(op, ":") {
+	case "-":
+		if useArg {
+			// the default value can have placeholders too
+			return expandPlaceholdersNames(arg, envVars)
+		}
+	case "?":
+		if useArg {
+			if arg == "" {
+				arg = "is not set"
+			}
+			return "", nil, fmt.Errorf("required env variable %s: %s", name, arg)
+		}
+	}
+	return value, []string{name}, nil
+}
+
+// splitPlaceholder split the placeholder expression in name, modifier and argument
+func splitPlaceholder(expr string) (name, op, arg string) {
+	i := strings.IndexAny(expr, ":-?")
+	if i < 0 {
+		return expr, "", ""
+	}
+	name, rest := expr[:i], expr[i:]
+	for _, op := range []string{":-", ":?", "-", "?"} {
+		if strings.HasPrefix(rest, op) {
+			return name, op, rest[len(op):]
+		}
+	}
+	// not a known modifier, use all as the name
+	return expr, "", ""
+}
+
+// mergeEnvVar replace the placeholders in all the strings of the map given (nested maps
+// and lists included), all the keys are processed and the errors are returned joined.
+// when types is given, the substituted values are converted to the types defined and
+// when used is given, the env variables used are added by key in `dot-notation`
+func mergeEnvVar(m, envVars ConfigMap, types *placeholderTypes, used map[string][]string, keys []string) (map[string]interface{}, error) {
+	var errs []error
+	for _, key := range sortedKeys(m) {
+		path := append(keys[:len(keys):len(keys)], key)
+		val, err := expandValue(m[key], envVars, types, used, path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		m[key] = val
+	}
+	return m, errors.Join(errs...)
+}
+
+func expandValue(val interface{}, envVars ConfigMap, types *placeholderTypes, used map[string][]string, path []string) (interface{}, error) {
+	switch value := val.(type) {
+	case ConfigMap:
+		// Recursive Call, keeping the type of the map
+		merged, err := mergeEnvVar(value, envVars, types, used, path)
+		return ConfigMap(merged), err
+	case map[string]interface{}:
+		// Recursive Call
+		return mergeEnvVar(value, envVars, types, used, path)
+	case []interface{}:
+		var errs []error
+		for i, item := range value {
+			expanded, err := expandValue(item, envVars, types, used, append(path[:len(path):len(path)], fmt.Sprint(i)))
+			if err != nil {
+				errs = append(errs, err)
+			}
+			value[i] = expanded
+		}
+		return value, errors.Join(errs...)
+	case string:
+		expanded, names, err := expandPlaceholdersNames(value, envVars)
+		if err != nil {
+			return "", fmt.Errorf("key %s: %w", buildKey(path), err)
+		}
+		if used != nil && len(names) > 0 {
+			used[buildKey(path)] = names
+		}
+		// only the values with placeholders are converted, escaped text is kept as string
+		if types == nil || !strings.Contains(strings.ReplaceAll(value, "$${", ""), "${") {
+			return expanded, nil
+		}
+		converted, err := types.convert(buildKey(path), expanded, isPlaceholder(value))
+		if err != nil {
+			return "", fmt.Errorf("key %s: %w", buildKey(path), err)
+		}
+		return converted, nil
+	}
+	return val, nil
+}
+
+// isPlaceholder return true if the string given is a single placeholder, like `${PORT}`
+func isPlaceholder(s string) bool {
+	return strings.HasPrefix(s, "${") && closingBrace(s, len("${")) == len(s)-1
+}
+
+// placeholderTypes define how the values of the substituted placeholders are converted
+type placeholderTypes struct {
+	// natural parse the values into their natural YAML scalar type
+	natural bool
+	// keys has the types declared for `dot-notation` keys
+	keys map[string]ValueType
+}
+
+// convert the value of the key given to its declared or natural type, only the
+// values of a whole placeholder, as given, are converted to their natural type
+func (t *placeholderTypes) convert(key, value string, whole bool) (interface{}, error) {
+	valueType, ok := t.keys[key]
+	if !ok {
+		if !t.natural || !whole {
+			return value, nil
+		}
+		valueType = TypeAuto
+	}
+	var (
+		converted interface{}
+		err       error
+	)
+	switch valueType {
+	case TypeAuto:
+		return naturalType(value), nil
+	case TypeString:
+		return value, nil
+	case TypeInt:
+		converted, err = cast.ToIntE(value)
+	case TypeFloat:
+		converted, err = cast.ToFloat64E(value)
+	case TypeBool:
+		converted, err = cast.ToBoolE(value)
+	case TypeDuration:
+		converted, err = time.ParseDuration(value)
+	default:
+		return nil, fmt.Errorf("unknown placeholder type %d", valueType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert %q to %s", value, valueType)
+	}
+	return converted, nil
+}
+
+// naturalType parse the value as a YAML scalar, returning an int, float, bool or nil
+// if the value is one of them, otherwise the value is returned as it is
+func naturalType(value string) interface{} {
+	if value == "" {
+		return value
+	}
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		return value
+	}
+	switch parsed.(type) {
+	case int, int64, uint64, float64, bool, nil:
+		return parsed
+	}
+	return value
+}
+
+// WithEnvPrefix enable the automatic binding of env variables to keys, any key
+// loaded (from defaults or files) is overridden by the env variable named as
+// the prefix and the key in upper case with the dots replaced by underscores,
+// like APP_SERVICES_LOGIN_PORT for the key `services.login.port`
+func (c *Config) WithEnvPrefix(prefix string) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.envPrefix = prefix
+	// if the build fails, the env variables are applied on the next load
+	_ = c.build()
+	return c
+}
+
+// WithEnvKeyReplacer set the replacer used to convert a key into the name
+// of the env variable, by default dots are replaced by underscores
+func (c *Config) WithEnvKeyReplacer(r *strings.Replacer) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.envReplacer = r
+	// if the build fails, the env variables are applied on the next load
+	_ = c.build()
+	return c
+}
+
+// BindEnv bind the key given in `dot-notation` to env variables, the first env
+// variable set override the value of the key, even if the key was not loaded before.
+// if no names are given, the name is built from the key as in WithEnvPrefix.
+// useful for keys that can't be found automatically
+func (c *Config) BindEnv(key string, envNames ...string) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.envBindings == nil {
+		c.envBindings = make(map[string][]string)
+	}
+	// the keys are normalized, so servers[0].host and servers.0.host are the same binding
+	c.envBindings[normalizeKey(key)] = envNames
+	// if the build fails, the env variables are applied on the next load
+	_ = c.build()
+	return c
+}
+
+// envName return the name of the env variable for the key given
+func (c *Config) envName(key string) string {
+	replacer := c.envReplacer
+	if replacer == nil {
+		replacer = strings.NewReplacer(".", "_")
+	}
+	name := strings.ToUpper(replacer.Replace(key))
+	if c.envPrefix == "" {
+		return name
+	}
+	return strings.ToUpper(c.envPrefix) + "_" + name
+}
+
+// mergeEnvOverrides override the values of the keys with the env variables bound to them,
+// adding their origins. the caller must hold the lock
+func (c *Config) mergeEnvOverrides(cm ConfigMap, origins map[string]Origin) (ConfigMap, error) {
+	bindings := make(map[string][]string, len(c.envBindings))
+	if c.envPrefix != "" {
+		// the lists can be overridden as a whole or by item
+		keys := flatten(cm, nil, Flatten(cm), false)
+		for key, val := range keys {
+			if _, isMap := asMap(val); !isMap {
+				bindings[key] = []string{c.envName(key)}
+			}
+		}
+	}
+	// explicit bindings have priority over the automatic ones
+	for key, envNames := range c.envBindings {
+		if len(envNames) < 1 {
+			envNames = []string{c.envName(key)}
+		}
+		bindings[key] = envNames
+	}
+
+	var errs []error
+	for _, key := range sortedBindings(bindings) {
+		for _, envName := range bindings[key] {
+			value, ok := os.LookupEnv(envName)
+			if !ok {
+				continue
+			}
+			var val interface{} = value
+			if c.envTypes != nil {
+				converted, err := c.envTypes.convert(key, value, true)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("env variable %s: %w", envName, err))
+					break
+				}
+				val = converted
+			}
+			SetValue(cm, splitKey(key), val)
+			origins[key] = Origin{Source: SourceEnv, Path: envName}
+			break
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("fail to override configs from env: %w", err)
+	}
+	return cm, nil
+}
+
+// sortedBindings return the keys of the bindings sorted
+func sortedBindings(bindings map[string][]string) []string {
+	keys := make([]string, 0, len(bindings))
+	for key := range bindings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	envVars := ConfigMap{
+		"HOST":  "127.0.0.1",
+		"PORT":  "3001",
+		"EMPTY": "",
+	}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"${HOST}", "127.0.0.1"},
+		{"http://${HOST}:${PORT}/api", "http://127.0.0.1:3001/api"},
+		{"${MISSING}", ""},
+		{"${MISSING:-fallback}", "fallback"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${EMPTY-fallback}", ""},
+		{"${MISSING-fallback}", "fallback"},
+		{"${HOST:-fallback}", "127.0.0.1"},
+		{"${MISSING:-${HOST}:${PORT}}", "127.0.0.1:3001"},
+		{"${MISSING:-}", ""},
+		{"${HOST:?host is required}", "127.0.0.1"},
+		{"${EMPTY?set but empty is fine}", ""},
+		{"$${HOST}", "${HOST}"},
+		{"cost: $5 ${PORT}", "cost: $5 3001"},
+		{"${UNTERMINATED", "${UNTERMINATED"},
+		{"no placeholders", "no placeholders"},
+	}
+	for _, test := range tests {
+		got, err := expandPlaceholders(test.in, envVars)
+		assert.NoError(t, err, test.in)
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+func TestExpandPlaceholdersRequired(t *testing.T) {
+	envVars := ConfigMap{"EMPTY": ""}
+
+	_, err := expandPlaceholders("${MISSING:?the missing value is needed}", envVars)
+	assert.EqualError(t, err, "required env variable MISSING: the missing value is needed")
+
+	_, err = expandPlaceholders("prefix-${EMPTY:?}", envVars)
+	assert.EqualError(t, err, "required env variable EMPTY: is not set")
+}
+
+func TestMergeEnvVarNested(t *testing.T) {
+	m := ConfigMap{
+		"url": "http://${HOST}:${PORT}",
+		"nested": map[string]interface{}{
+			"list": []interface{}{"${HOST}", 1, map[string]interface{}{"port": "${PORT}"}},
+		},
+		"required": "${MISSING:?}",
+	}
+	want := map[string]interface{}{
+		"url": "http://localhost:80",
+		"nested": map[string]interface{}{
+			"list": []interface{}{"localhost", 1, map[string]interface{}{"port": "80"}},
+		},
+		"required": "",
+	}
+
+	got := MergeEnvVar(m, ConfigMap{"HOST": "localhost", "PORT": "80"})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MergeEnvVar(nested) = %#v, want %#v", got, want)
+	}
+}
+
+func TestLoadConfigsRequiredPlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	content := "app:\n  url: http://${REQUIRED_TEST_HOST:?the host is required}:${REQUIRED_TEST_PORT:-3001}\n"
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+
+	os.Unsetenv("REQUIRED_TEST_HOST")
+	config := New().WithEnv()
+	err := config.LoadConfigs(file)
+	assert.ErrorContains(t, err, "key app.url: required env variable REQUIRED_TEST_HOST: the host is required")
+	assert.Nil(t, config.Get("app.url"))
+
+	os.Setenv("REQUIRED_TEST_HOST", "localhost")
+	defer os.Unsetenv("REQUIRED_TEST_HOST")
+	config = New().WithEnv()
+	assert.NoError(t, config.LoadConfigs(file))
+	assert.Equal(t, "http://localhost:3001", config.Get("app.url"))
+
+	// without WithEnv the variables of the process are used
+	config = New()
+	assert.NoError(t, config.LoadConfigs(file))
+	assert.Equal(t, "http://localhost:3001", config.Get("app.url"))
+	os.Unsetenv("REQUIRED_TEST_HOST")
+	config = New()
+	err = config.LoadConfigs(file)
+	assert.ErrorContains(t, err, "key app.url: required env variable REQUIRED_TEST_HOST: the host is required")
+	escaped := filepath.Join(dir, "escaped.yaml")
+	assert.NoError(t, os.WriteFile(escaped, []byte("app:\n  url: $${HOST}:${PORT:-3001}\n"), 0644))
+	config = New()
+	assert.NoError(t, config.LoadConfigs(escaped))
+	assert.Equal(t, "${HOST}:3001", config.Get("app.url"))
+}
+
+func TestTypedPlaceholders(t *testing.T) {
+	envVars := ConfigMap{
+		"PORT":    "3001",
+		"RATIO":   "0.5",
+		"ENABLED": "true",
+		"NOTHING": "null",
+		"NAME":    "service",
+		"TIMEOUT": "5s",
+		"MAJOR":   "1",
+		"MINOR":   "10",
+	}
+
+	t.Run("test natural types", func(t *testing.T) {
+		m := ConfigMap{
+			"port":    "${PORT}",
+			"ratio":   "${RATIO}",
+			"enabled": "${ENABLED}",
+			"nothing": "${NOTHING}",
+			"name":    "${NAME}",
+			"url":     "http://localhost:${PORT}",
+			"escaped": "$${PORT}",
+			"literal": "3002",
+			"missing": "${MISSING}",
+			"version": "${MAJOR}.${MINOR}",
+			"zip":     "0${MAJOR}",
+			"default": "${MISSING:-${PORT}}",
+		}
+		got, err := mergeEnvVar(m, envVars, &placeholderTypes{natural: true}, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"port":    3001,
+			"ratio":   0.5,
+			"enabled": true,
+			"nothing": nil,
+			"name":    "service",
+			"url":     "http://localhost:3001",
+			"escaped": "${PORT}",
+			"literal": "3002",
+			"missing": "",
+			// the values inside a larger string stay strings
+			"version": "1.10",
+			"zip":     "01",
+			"default": 3001,
+		}, got)
+	})
+
+	t.Run("test declared types", func(t *testing.T) {
+		types := &placeholderTypes{keys: map[string]ValueType{
+			"server.port":    TypeInt,
+			"server.timeout": TypeDuration,
+			"server.name":    TypeString,
+			"server.ratio":   TypeFloat,
+		}}
+		m := ConfigMap{
+			"server": map[string]interface{}{
+				"port":    "${PORT}",
+				"timeout": "${TIMEOUT}",
+				"name":    "${PORT}",
+				"ratio":   "${PORT}",
+				"enabled": "${ENABLED}",
+			},
+		}
+		got, err := mergeEnvVar(m, envVars, types, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"port":    3001,
+			"timeout": 5 * time.Second,
+			"name":    "3001",
+			"ratio":   float64(3001),
+			"enabled": "true",
+		}, got["server"])
+	})
+
+	t.Run("test declared type conversion error", func(t *testing.T) {
+		types := &placeholderTypes{keys: map[string]ValueType{"port": TypeInt}}
+		_, err := mergeEnvVar(ConfigMap{"port": "${NAME}"}, envVars, types, nil, nil)
+		assert.EqualError(t, err, `key port: unable to convert "service" to int`)
+	})
+
+	t.Run("test LoadConfigs with typed placeholders", func(t *testing.T) {
+		os.Setenv("TYPED_TEST_PORT", "3001")
+		os.Setenv("TYPED_TEST_TIMEOUT", "1m")
+		defer os.Unsetenv("TYPED_TEST_PORT")
+		defer os.Unsetenv("TYPED_TEST_TIMEOUT")
+		dir := t.TempDir()
+		file := filepath.Join(dir, "config.yaml")
+		content := "app:\n  port: ${TYPED_TEST_PORT}\n  timeout: ${TYPED_TEST_TIMEOUT}\n"
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+
+		config := New().WithEnv().WithTypedPlaceholders().
+			WithPlaceholderTypes(map[string]ValueType{"app.timeout": TypeDuration})
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.Equal(t, 3001, config.Get("app.port"))
+		assert.Equal(t, time.Minute, config.Get("app.timeout"))
+		assert.Equal(t, map[string]interface{}{"app.port": 3001, "app.timeout": time.Minute}, Flatten(config.Snapshot()))
+	})
+}
+
+func TestEnvOverrides(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	content := "services:\n  login:\n    host: 127.0.0.1\n    port: 3002\nlogger:\n  log_level: info\n"
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+
+	t.Run("test override keys with prefix", func(t *testing.T) {
+		t.Setenv("APP_SERVICES_LOGIN_PORT", "4000")
+		t.Setenv("APP_LOGGER_LOG_LEVEL", "debug")
+		t.Setenv("SERVICES_LOGIN_HOST", "not-prefixed")
+
+		config := New().WithEnvPrefix("app")
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.Equal(t, "4000", config.Get("services.login.port"))
+		assert.Equal(t, "debug", config.Get("logger.log_level"))
+		assert.Equal(t, "127.0.0.1", config.Get("services.login.host"))
+	})
+
+	t.Run("test no overrides without prefix", func(t *testing.T) {
+		t.Setenv("SERVICES_LOGIN_PORT", "4000")
+
+		config := New()
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.Equal(t, 3002, config.Get("services.login.port"))
+	})
+
+	t.Run("test override with key replacer and typed values", func(t *testing.T) {
+		t.Setenv("APP__SERVICES__LOGIN__PORT", "4000")
+
+		config := New().WithEnvPrefix("APP_").WithEnvKeyReplacer(strings.NewReplacer(".", "__")).WithTypedPlaceholders()
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.Equal(t, 4000, config.Get("services.login.port"))
+	})
+
+	t.Run("test BindEnv", func(t *testing.T) {
+		t.Setenv("LOGIN_USER", "admin")
+		t.Setenv("APP_SERVICES_LOGIN_PASSWORD", "secret")
+		t.Setenv("SECOND_HOST", "10.0.0.1")
+
+		config := New().
+			BindEnv("services.login.user", "LOGIN_USER").
+			BindEnv("services.login.password").
+			BindEnv("services.login.host", "FIRST_HOST", "SECOND_HOST").
+			WithEnvPrefix("APP")
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.Equal(t, "admin", config.Get("services.login.user"))
+		assert.Equal(t, "secret", config.Get("services.login.password"))
+		assert.Equal(t, "10.0.0.1", config.Get("services.login.host"))
+	})
+
+	t.Run("test BindEnv normalize the keys", func(t *testing.T) {
+		t.Setenv("FIRST_SERVER", "10.0.0.1")
+
+		config := New().BindEnv("servers[0].host", "FIRST_SERVER")
+		config.SetConfigMap(ConfigMap{"servers": []interface{}{ConfigMap{"host": "localhost"}}})
+		assert.Equal(t, "10.0.0.1", config.Get("servers.0.host"))
+		origin, ok := config.Origin("servers[0].host")
+		assert.True(t, ok)
+		assert.Equal(t, Origin{Source: SourceEnv, Path: "FIRST_SERVER"}, origin)
+	})
+
+	t.Run("test env settings applied to the configs loaded", func(t *testing.T) {
+		t.Setenv("PRB_APP_PORT", "9")
+		t.Setenv("PRB__APP__HOST", "example.com")
+		t.Setenv("APP_NAME", "service")
+
+		config := New()
+		config.SetConfigMap(ConfigMap{"app": ConfigMap{"port": 3001, "host": "localhost"}})
+		config.WithEnvPrefix("PRB")
+		assert.Equal(t, "9", config.Get("app.port"))
+		config.WithEnvKeyReplacer(strings.NewReplacer(".", "__")).WithEnvPrefix("PRB_")
+		assert.Equal(t, "example.com", config.Get("app.host"))
+		config.BindEnv("app.name", "APP_NAME")
+		assert.Equal(t, "service", config.Get("app.name"))
+	})
+
+	t.Run("test override before Unmarshal", func(t *testing.T) {
+		t.Setenv("APP_LOGGER_LOG_LEVEL", "warn")
+
+		mock := &MockConfig{}
+		config := New().WithEnvPrefix("APP")
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.NoError(t, config.Unmarshal(mock))
+		assert.Equal(t, "warn", mock.Logger.Level)
+	})
+}
@@ -1,48 +1,125 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
 )
 
-// ReadFile is a function to read a file and decode,
-// supporting only yaml and json at the moment
+// ReadFile is a function to read a file and decode it
+// using the decoder registered for the file extension,
+// the files included are relative to the file
 func ReadFile(file string) (ConfigMap, error) {
 	content, err := os.ReadFile(filepath.Clean(file))
 	if err != nil {
 		return nil, err
 	}
-	ext := getFileExt(file)
-	dataMap := make(ConfigMap)
+	cm, err := decode(content, getFileExt(file))
+	if err != nil {
+		return nil, err
+	}
+	cm, _, err = resolveIncludes(cm, nil, file)
+	return cm, err
+}
+
+// ReadFS is a function to read a file from a fs.FS (like embed.FS) and decode it
+// using the decoder registered for the file extension
+func ReadFS(fsys fs.FS, file string) (ConfigMap, error) {
+	content, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	cm, err := decode(content, getFileExt(file))
+	if err != nil {
+		return nil, err
+	}
+	cm, _, err = resolveIncludes(cm, fsys, file)
+	return cm, err
+}
 
-	switch true {
-	case ext == "json":
-		err = jsonDecode(content, &dataMap)
-	case ext == "yaml" || ext == "yml":
-		err = yamlDecode(content, &dataMap)
-	default:
-		err = fmt.Errorf("invalid extension type: %s", ext)
+// ReadReader is a function to read the content from a io.Reader and decode it
+// using the decoder registered for the format given (json, yaml, toml...),
+// the files included are relative to the current directory
+func ReadReader(r io.Reader, format string) (ConfigMap, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
 	}
+	cm, err := decode(content, format)
 	if err != nil {
 		return nil, err
 	}
-	return dataMap, nil
+	cm, _, err = resolveIncludes(cm, nil, "")
+	return cm, err
+}
+
+// decode the content using the decoder registered for the extension
+func decode(content []byte, ext string) (ConfigMap, error) {
+	fn, ok := getDecoder(ext)
+	if !ok {
+		return nil, fmt.Errorf("invalid extension type: %s", normalizeExt(ext))
+	}
+	return fn(content)
 }
 
 func jsonDecode(j []byte, d *ConfigMap) error {
 	return json.Unmarshal(j, d)
 }
 
+// yamlDecode decode the YAML content, the keys with the `!delete` tag are set
+// to Delete and the keys with the `!include` tag to the file to include
 func yamlDecode(j []byte, d *ConfigMap) error {
-	return yaml.Unmarshal(j, d)
+	if err := yaml.Unmarshal(j, d); err != nil {
+		return err
+	}
+	if !bytes.Contains(j, []byte(deleteTag)) && !bytes.Contains(j, []byte(includeTag)) {
+		return nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(j, &doc); err != nil || len(doc.Content) < 1 {
+		return err
+	}
+	return setYamlTags(*d, doc.Content[0], nil)
+}
+
+// setYamlTags set the values of the nodes with the `!delete` and `!include` tags in the map given
+func setYamlTags(m ConfigMap, node *yaml.Node, keys []string) error {
+	switch node.Tag {
+	case deleteTag:
+		SetValue(m, keys, Delete)
+		return nil
+	case includeTag:
+		if node.Kind != yaml.ScalarNode || node.Value == "" {
+			return fmt.Errorf("line %d: %s should be a file path", node.Line, includeTag)
+		}
+		SetValue(m, keys, includeFile(node.Value))
+		return nil
+	}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			if err := setYamlTags(m, item, append(keys[:len(keys):len(keys)], strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := setYamlTags(m, node.Content[i+1], append(keys[:len(keys):len(keys)], node.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func getFileExt(s string) (ext string) {
 	fullExt := filepath.Ext(s)
-	return strings.TrimPrefix(fullExt, ".")
+	return normalizeExt(fullExt)
 }
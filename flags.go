@@ -0,0 +1,178 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// FlagValue is a command-line flag that can be bound to a key, flags
+// from other packages (like spf13/pflag) can be bound implementing it
+type FlagValue interface {
+	// Name of the flag, used as key in `dot-notation`
+	Name() string
+	// HasChanged return true if the flag was given in the command-line
+	HasChanged() bool
+	// ValueString return the value of the flag as string
+	ValueString() string
+	// ValueType return the type of the flag (string, bool, int, int64, uint,
+	// uint64, float64, duration, stringSlice...) used to convert the value
+	ValueType() string
+}
+
+// FlagValueSet is a set of command-line flags that can be bound to keys
+type FlagValueSet interface {
+	VisitAll(fn func(FlagValue))
+}
+
+// BindFlagSet bind the flags of the flag.FlagSet given to the keys named as the flags,
+// so `--app.port=4000` override the value of `app.port` from defaults, files and env.
+// only the flags given in the command-line are used, flags are read again when
+// the configurations are read, so they can be parsed after binding them
+func (c *Config) BindFlagSet(fs *flag.FlagSet) *Config {
+	return c.BindFlagValues(stdFlagSet{fs})
+}
+
+// BindFlagValues bind the flags of the FlagValueSet given to the keys named as the flags,
+// same as BindFlagSet
+func (c *Config) BindFlagValues(fvs FlagValueSet) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flagSets = append(c.flagSets, fvs)
+	// if the build fails, the flags are applied on the next load
+	_ = c.build()
+	return c
+}
+
+// syncFlags build the configurations again if the values of the flags
+// changed since the last build, like when they are parsed after binding them
+func (c *Config) syncFlags() {
+	c.mu.RLock()
+	changed := len(c.flagSets) > 0 && !reflect.DeepEqual(c.flagValues(), c.builtFlags)
+	c.mu.RUnlock()
+	if !changed {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !reflect.DeepEqual(c.flagValues(), c.builtFlags) {
+		// if the build fails, the flags are applied on the next load
+		_ = c.build()
+	}
+}
+
+// flagValues return the values of the flags given in the command-line
+// by flag name. the caller must hold the lock
+func (c *Config) flagValues() []override {
+	var values []override
+	for _, fvs := range c.flagSets {
+		fvs.VisitAll(func(f FlagValue) {
+			if f.HasChanged() {
+				values = setOverride(values, f.Name(), flagValue(f))
+			}
+		})
+	}
+	return values
+}
+
+// flagValue convert the value of the flag to its type,
+// if the value can't be converted is returned as string
+func flagValue(f FlagValue) interface{} {
+	value := f.ValueString()
+	var (
+		converted interface{}
+		err       error
+	)
+	switch f.ValueType() {
+	case "bool":
+		converted, err = cast.ToBoolE(value)
+	case "int":
+		converted, err = cast.ToIntE(value)
+	case "int8", "int16", "int32", "int64":
+		converted, err = cast.ToInt64E(value)
+	case "uint":
+		converted, err = cast.ToUintE(value)
+	case "uint8", "uint16", "uint32", "uint64":
+		converted, err = cast.ToUint64E(value)
+	case "float32", "float64":
+		converted, err = cast.ToFloat64E(value)
+	case "duration":
+		converted, err = time.ParseDuration(value)
+	case "stringSlice", "stringArray":
+		items := strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		if items == "" {
+			return []interface{}{}
+		}
+		list := make([]interface{}, 0)
+		for _, item := range strings.Split(items, ",") {
+			list = append(list, item)
+		}
+		return list
+	default:
+		return value
+	}
+	if err != nil {
+		return value
+	}
+	return converted
+}
+
+// stdFlagSet is the FlagValueSet for a flag.FlagSet
+type stdFlagSet struct {
+	fs *flag.FlagSet
+}
+
+func (s stdFlagSet) VisitAll(fn func(FlagValue)) {
+	changed := make(map[string]bool)
+	s.fs.Visit(func(f *flag.Flag) {
+		changed[f.Name] = true
+	})
+	s.fs.VisitAll(func(f *flag.Flag) {
+		fn(stdFlag{flag: f, changed: changed[f.Name]})
+	})
+}
+
+// stdFlag is the FlagValue for a flag.Flag
+type stdFlag struct {
+	flag    *flag.Flag
+	changed bool
+}
+
+func (f stdFlag) Name() string {
+	return f.flag.Name
+}
+
+func (f stdFlag) HasChanged() bool {
+	return f.changed
+}
+
+func (f stdFlag) ValueString() string {
+	return f.flag.Value.String()
+}
+
+func (f stdFlag) ValueType() string {
+	getter, ok := f.flag.Value.(flag.Getter)
+	if !ok {
+		return "string"
+	}
+	switch getter.Get().(type) {
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case int64:
+		return "int64"
+	case uint:
+		return "uint"
+	case uint64:
+		return "uint64"
+	case float64:
+		return "float64"
+	case time.Duration:
+		return "duration"
+	}
+	return "string"
+}
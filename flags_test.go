@@ -0,0 +1,122 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockFlag is a FlagValue like the ones from spf13/pflag
+type mockFlag struct {
+	name, value, valueType string
+	changed                bool
+}
+
+func (f mockFlag) Name() string        { return f.name }
+func (f mockFlag) HasChanged() bool    { return f.changed }
+func (f mockFlag) ValueString() string { return f.value }
+func (f mockFlag) ValueType() string   { return f.valueType }
+
+type mockFlagSet []mockFlag
+
+func (s mockFlagSet) VisitAll(fn func(FlagValue)) {
+	for _, f := range s {
+		fn(f)
+	}
+}
+
+func TestBindFlagSet(t *testing.T) {
+	t.Run("test only flags given are used", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int("app.port", 3001, "port")
+		fs.Bool("app.debug", false, "debug")
+		fs.Duration("app.timeout", time.Second, "timeout")
+		fs.String("app.host", "127.0.0.1", "host")
+		assert.NoError(t, fs.Parse([]string{"--app.port=4000", "--app.debug", "--app.timeout=1m"}))
+
+		config := New().BindFlagSet(fs)
+		assert.Equal(t, 4000, config.Get("app.port"))
+		assert.Equal(t, true, config.Get("app.debug"))
+		assert.Equal(t, time.Minute, config.Get("app.timeout"))
+		assert.Nil(t, config.Get("app.host"))
+	})
+
+	t.Run("test flags parsed after binding", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "config.yaml")
+		assert.NoError(t, os.WriteFile(file, []byte("app:\n  port: 3001\n"), 0644))
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int("app.port", 0, "port")
+		config := New().BindFlagSet(fs)
+		assert.NoError(t, fs.Parse([]string{"--app.port=4000"}))
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.Equal(t, 4000, config.Get("app.port"))
+	})
+
+	t.Run("test flags parsed after binding without loading again", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int("app.port", 0, "port")
+		config := New()
+		config.SetConfigMap(ConfigMap{"app": ConfigMap{"port": 3001}})
+		config.BindFlagSet(fs)
+		assert.Equal(t, 3001, config.Get("app.port"))
+		assert.NoError(t, fs.Parse([]string{"--app.port=4000"}))
+		assert.Equal(t, 4000, config.Get("app.port"))
+		origin, _ := config.Origin("app.port")
+		assert.Equal(t, Origin{Source: SourceFlag, Path: "app.port"}, origin)
+	})
+}
+
+func TestBindFlagValues(t *testing.T) {
+	config := New().BindFlagValues(mockFlagSet{
+		{name: "app.port", value: "4000", valueType: "int", changed: true},
+		{name: "app.ratio", value: "0.5", valueType: "float64", changed: true},
+		{name: "app.tags", value: "[a,b]", valueType: "stringSlice", changed: true},
+		{name: "app.size", value: "not-a-number", valueType: "int", changed: true},
+		{name: "app.host", value: "127.0.0.1", valueType: "string", changed: false},
+	})
+	assert.Equal(t, 4000, config.Get("app.port"))
+	assert.Equal(t, 0.5, config.Get("app.ratio"))
+	assert.Equal(t, []interface{}{"a", "b"}, config.Get("app.tags"))
+	assert.Equal(t, "not-a-number", config.Get("app.size"))
+	assert.Nil(t, config.Get("app.host"))
+}
+
+func TestPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	content := "default: file\nfile: file\nenv: file\nflag: file\nset: file\n"
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	t.Setenv("APP_ENV", "env")
+	t.Setenv("APP_FLAG", "env")
+	t.Setenv("APP_SET", "env")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("flag", "", "")
+	fs.String("set", "", "")
+	assert.NoError(t, fs.Parse([]string{"--flag=flag", "--set=flag"}))
+
+	config := New().WithEnvPrefix("APP")
+	// the order of the calls don't change the precedence
+	config.Set("set", "set")
+	config.SetDefault("only_default", "default")
+	config.SetDefault("default", "default")
+	config.BindFlagSet(fs)
+	assert.NoError(t, config.LoadConfigs(file))
+
+	assert.Equal(t, "default", config.Get("only_default"))
+	assert.Equal(t, "file", config.Get("default"))
+	assert.Equal(t, "file", config.Get("file"))
+	assert.Equal(t, "env", config.Get("env"))
+	assert.Equal(t, "flag", config.Get("flag"))
+	assert.Equal(t, "set", config.Get("set"))
+
+	// values set are kept after the sources are loaded again
+	assert.NoError(t, config.reload())
+	assert.Equal(t, "set", config.Get("set"))
+}
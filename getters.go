@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+)
+
+// ErrKeyNotFound is returned when a key is not set in the configurations
+var ErrKeyNotFound = errors.New("key not found")
+
+// Lookup return the value of the key given in `dot-notation`,
+// and false if the key is not set. the value should not be modified
+func (c *Config) Lookup(key string) (interface{}, bool) {
+	c.syncFlags()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return lookupValue(c.ConfigMap, splitKey(key))
+}
+
+// GetAs is a function to get the value of the key given in `dot-notation`
+// converted to the type T. the error wraps ErrKeyNotFound if the key is not set,
+// otherwise the value could not be converted. strings, bools, ints, uints, floats,
+// time.Duration, time.Time, []string, []int and map[string]string are converted
+// with cast, any other type (like map[string]interface{}, structs or net.IP)
+// is decoded as in Unmarshal
+func GetAs[T any](c *Config, key string) (T, error) {
+	var out T
+	val, ok := c.Lookup(key)
+	if !ok {
+		return out, fmt.Errorf("key %s: %w", key, ErrKeyNotFound)
+	}
+	if m, isMap := asMap(val); isMap {
+		// cast only knows map[string]interface{}
+		val = m
+	}
+	converted, handled, err := castTo(any(out), val)
+	switch {
+	case handled && err == nil:
+		return converted.(T), nil
+	case !handled:
+		if v, ok := val.(T); ok {
+			return copyValue(v).(T), nil
+		}
+		c.mu.RLock()
+		hook := c.decodeHook()
+		c.mu.RUnlock()
+		if err = decodeValue(val, &out, hook); err == nil {
+			return out, nil
+		}
+	}
+	var zero T
+	return zero, fmt.Errorf("key %s: unable to convert %v (%T) to %T: %w", key, val, val, zero, err)
+}
+
+// castTo convert the value to the type of target with cast,
+// returning false if the type is not supported by cast
+func castTo(target, val interface{}) (interface{}, bool, error) {
+	var (
+		converted interface{}
+		err       error
+	)
+	switch target.(type) {
+	case string:
+		converted, err = cast.ToStringE(val)
+	case bool:
+		converted, err = cast.ToBoolE(val)
+	case int:
+		converted, err = cast.ToIntE(val)
+	case int8:
+		converted, err = cast.ToInt8E(val)
+	case int16:
+		converted, err = cast.ToInt16E(val)
+	case int32:
+		converted, err = cast.ToInt32E(val)
+	case int64:
+		converted, err = cast.ToInt64E(val)
+	case uint:
+		converted, err = cast.ToUintE(val)
+	case uint8:
+		converted, err = cast.ToUint8E(val)
+	case uint16:
+		converted, err = cast.ToUint16E(val)
+	case uint32:
+		converted, err = cast.ToUint32E(val)
+	case uint64:
+		converted, err = cast.ToUint64E(val)
+	case float32:
+		converted, err = cast.ToFloat32E(val)
+	case float64:
+		converted, err = cast.ToFloat64E(val)
+	case time.Duration:
+		converted, err = cast.ToDurationE(val)
+	case time.Time:
+		converted, err = cast.ToTimeE(val)
+	case []string:
+		converted, err = cast.ToStringSliceE(val)
+	case []int:
+		converted, err = cast.ToIntSliceE(val)
+	case map[string]string:
+		converted, err = cast.ToStringMapStringE(val)
+	default:
+		return nil, false, nil
+	}
+	return converted, true, err
+}
+
+// decodeValue decode any value into out using mapstructure with the hook given
+func decodeValue(val, out interface{}, hook mapstructure.DecodeHookFunc) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       hook,
+		Result:           out,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(val)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	config := New()
+	config.SetConfigMap(ConfigMap{"app": ConfigMap{"name": "service", "empty": nil}})
+
+	val, ok := config.Lookup("app.name")
+	assert.True(t, ok)
+	assert.Equal(t, "service", val)
+
+	val, ok = config.Lookup("app.empty")
+	assert.True(t, ok)
+	assert.Nil(t, val)
+
+	_, ok = config.Lookup("app.port")
+	assert.False(t, ok)
+	_, ok = config.Lookup("app.name.first")
+	assert.False(t, ok)
+}
+
+func TestGetAs(t *testing.T) {
+	config := New()
+	assert.NoError(t, config.LoadReader(strings.NewReader(`
+app:
+  port: "3001"
+  ratio: 0.75
+  timeout: 5s
+  retries: 3
+  started: 2024-01-02T03:04:05Z
+  hosts: [a, b]
+  ports: [80, 443]
+  labels:
+    team: core
+    tier: 1
+  ip: 10.0.0.1
+`), "yaml"))
+
+	port, err := GetAs[int](config, "app.port")
+	assert.NoError(t, err)
+	assert.Equal(t, 3001, port)
+
+	ratio, err := GetAs[float64](config, "app.ratio")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.75, ratio)
+
+	timeout, err := GetAs[time.Duration](config, "app.timeout")
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, timeout)
+
+	retries, err := GetAs[uint8](config, "app.retries")
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(3), retries)
+
+	started, err := GetAs[time.Time](config, "app.started")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), started.UTC())
+
+	hosts, err := GetAs[[]string](config, "app.hosts")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, hosts)
+
+	ports, err := GetAs[[]int](config, "app.ports")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{80, 443}, ports)
+
+	labels, err := GetAs[map[string]string](config, "app.labels")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "core", "tier": "1"}, labels)
+
+	app, err := GetAs[map[string]interface{}](config, "app.labels")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"team": "core", "tier": 1}, app)
+
+	ip, err := GetAs[net.IP](config, "app.ip")
+	assert.NoError(t, err)
+	assert.True(t, ip.Equal(net.ParseIP("10.0.0.1")))
+
+	_, err = GetAs[int](config, "app.missing")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+	assert.EqualError(t, err, "key app.missing: key not found")
+
+	_, err = GetAs[int](config, "app.timeout")
+	assert.False(t, errors.Is(err, ErrKeyNotFound))
+	assert.ErrorContains(t, err, "key app.timeout: unable to convert 5s (string) to int")
+
+	_, err = GetAs[net.IP](config, "app.hosts")
+	assert.ErrorContains(t, err, "key app.hosts: unable to convert")
+
+	err = config.UnmarshalKey("app.missing", &MockConfig{})
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+}
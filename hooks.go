@@ -0,0 +1,155 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// ByteSize is a size in bytes decoded from strings like "512", "10KB" or "10MiB",
+// the units KB, MB, GB, TB, PB and EB are powers of 1000 and KiB, MiB, GiB, TiB,
+// PiB and EiB powers of 1024, the B is optional and the units are case insensitive
+type ByteSize uint64
+
+// byteUnits are the multipliers of the units, without the B
+var byteUnits = map[string]float64{
+	"":   1,
+	"k":  1e3,
+	"m":  1e6,
+	"g":  1e9,
+	"t":  1e12,
+	"p":  1e15,
+	"e":  1e18,
+	"ki": 1 << 10,
+	"mi": 1 << 20,
+	"gi": 1 << 30,
+	"ti": 1 << 40,
+	"pi": 1 << 50,
+	"ei": 1 << 60,
+}
+
+// ParseByteSize is a function to parse a size in bytes like "10MiB"
+func ParseByteSize(s string) (ByteSize, error) {
+	value := strings.TrimSpace(s)
+	i := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(value)
+	}
+	number, err := strconv.ParseFloat(value[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	unit := strings.ToLower(strings.TrimSpace(value[i:]))
+	multiplier, ok := byteUnits[strings.TrimSuffix(unit, "b")]
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size %q: unknown unit %q", s, value[i:])
+	}
+	size := number * multiplier
+	if size >= math.MaxUint64 {
+		return 0, fmt.Errorf("invalid byte size %q: out of range", s)
+	}
+	return ByteSize(size), nil
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+// DefaultDecodeHook return the decode hooks used by Unmarshal, converting strings to
+// time.Duration, net.IP, net.IPNet (CIDR), url.URL, regexp.Regexp, ByteSize and any
+// type implementing encoding.TextUnmarshaler, for values and pointers
+func DefaultDecodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		stringParseHook(reflect.TypeOf(net.IP{}), func(s string) (interface{}, error) {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %q", s)
+			}
+			return ip, nil
+		}),
+		stringParseHook(reflect.TypeOf(net.IPNet{}), func(s string) (interface{}, error) {
+			_, ipNet, err := net.ParseCIDR(s)
+			return ipNet, err
+		}),
+		stringParseHook(reflect.TypeOf(url.URL{}), func(s string) (interface{}, error) {
+			return url.Parse(s)
+		}),
+		stringParseHook(reflect.TypeOf(regexp.Regexp{}), func(s string) (interface{}, error) {
+			return regexp.Compile(s)
+		}),
+		textUnmarshalerHook(),
+	)
+}
+
+// AddDecodeHook add decode hooks used by Unmarshal, they are called in order
+// after the DefaultDecodeHook, so they receive the values already converted
+func (c *Config) AddDecodeHook(hooks ...mapstructure.DecodeHookFunc) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.decodeHooks = append(c.decodeHooks[:len(c.decodeHooks):len(c.decodeHooks)], hooks...)
+	return c
+}
+
+// decodeHook return the default decode hook with the hooks added. the caller must hold the lock
+func (c *Config) decodeHook() mapstructure.DecodeHookFunc {
+	if len(c.decodeHooks) == 0 {
+		return DefaultDecodeHook()
+	}
+	return mapstructure.ComposeDecodeHookFunc(append([]mapstructure.DecodeHookFunc{DefaultDecodeHook()}, c.decodeHooks...)...)
+}
+
+// stringParseHook convert the strings with the parse function when
+// the target is the type given or a pointer to it
+func stringParseHook(typ reflect.Type, parse func(s string) (interface{}, error)) mapstructure.DecodeHookFuncType {
+	ptrType := reflect.PointerTo(typ)
+	return func(f, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || (t != typ && t != ptrType) {
+			return data, nil
+		}
+		return parse(data.(string))
+	}
+}
+
+// textUnmarshalerHook convert the strings with UnmarshalText
+// when the target or a pointer to it implements encoding.TextUnmarshaler
+func textUnmarshalerHook() mapstructure.DecodeHookFuncType {
+	return func(f, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+		isPtr := t.Kind() == reflect.Pointer
+		elemType := t
+		if isPtr {
+			elemType = t.Elem()
+		}
+		if !reflect.PointerTo(elemType).Implements(textUnmarshalerType) {
+			return data, nil
+		}
+		result := reflect.New(elemType)
+		if err := result.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(data.(string))); err != nil {
+			return nil, err
+		}
+		if isPtr {
+			return result.Interface(), nil
+		}
+		return result.Elem().Interface(), nil
+	}
+}
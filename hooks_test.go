@@ -0,0 +1,121 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/stretchr/testify/assert"
+)
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "debug":
+		*l = 0
+	case "info":
+		*l = 1
+	default:
+		return &net.ParseError{Type: "level", Text: string(text)}
+	}
+	return nil
+}
+
+type hooksConfig struct {
+	Timeout  time.Duration  `mapstructure:"timeout"`
+	IP       net.IP         `mapstructure:"ip"`
+	Network  net.IPNet      `mapstructure:"network"`
+	Networks []*net.IPNet   `mapstructure:"networks"`
+	URL      url.URL        `mapstructure:"url"`
+	Proxy    *url.URL       `mapstructure:"proxy"`
+	Pattern  *regexp.Regexp `mapstructure:"pattern"`
+	MaxSize  ByteSize       `mapstructure:"max_size"`
+	MinSize  ByteSize       `mapstructure:"min_size"`
+	Level    level          `mapstructure:"level"`
+	Started  time.Time      `mapstructure:"started"`
+	Tags     []string       `mapstructure:"tags"`
+}
+
+func TestDefaultDecodeHook(t *testing.T) {
+	config := New()
+	config.SetConfigMap(ConfigMap{
+		"timeout":  "5s",
+		"ip":       "10.0.0.1",
+		"network":  "10.0.0.0/8",
+		"networks": []interface{}{"192.168.0.0/16"},
+		"url":      "https://example.com/path",
+		"proxy":    "http://proxy:3128",
+		"pattern":  "^a+$",
+		"max_size": "10MiB",
+		"min_size": 512,
+		"level":    "info",
+		"started":  "2024-01-02T03:04:05Z",
+		"tags":     []interface{}{"a", "b"},
+	})
+	cfg := &hooksConfig{}
+	assert.NoError(t, config.Unmarshal(cfg))
+	assert.Equal(t, 5*time.Second, cfg.Timeout)
+	assert.True(t, cfg.IP.Equal(net.ParseIP("10.0.0.1")))
+	assert.Equal(t, "10.0.0.0/8", cfg.Network.String())
+	assert.Equal(t, "192.168.0.0/16", cfg.Networks[0].String())
+	assert.Equal(t, "example.com", cfg.URL.Host)
+	assert.Equal(t, "proxy:3128", cfg.Proxy.Host)
+	assert.True(t, cfg.Pattern.MatchString("aaa"))
+	assert.Equal(t, ByteSize(10<<20), cfg.MaxSize)
+	assert.Equal(t, ByteSize(512), cfg.MinSize)
+	assert.Equal(t, level(1), cfg.Level)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), cfg.Started)
+	assert.Equal(t, []string{"a", "b"}, cfg.Tags)
+
+	config.Set("ip", "not an ip")
+	err := config.Unmarshal(&hooksConfig{})
+	assert.ErrorContains(t, err, `invalid ip "not an ip"`)
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]ByteSize{
+		"512":    512,
+		"1B":     1,
+		"10KB":   10000,
+		"10kb":   10000,
+		"10KiB":  10240,
+		"1.5 MB": 1500000,
+		"10MiB":  10 << 20,
+		"2GiB":   2 << 30,
+		"1TB":    1e12,
+		"1k":     1000,
+	}
+	for value, expected := range tests {
+		size, err := ParseByteSize(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, size, value)
+	}
+	for _, value := range []string{"", "MB", "10XB", "1.2.3KB", "100000EB"} {
+		_, err := ParseByteSize(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestAddDecodeHook(t *testing.T) {
+	upper := func(f, t reflect.Type, data interface{}) (interface{}, error) {
+		if s, ok := data.(string); ok && t.Kind() == reflect.String {
+			return strings.ToUpper(s), nil
+		}
+		return data, nil
+	}
+	config := New().AddDecodeHook(mapstructure.DecodeHookFuncType(upper))
+	config.SetConfigMap(ConfigMap{"name": "service", "timeout": "1m"})
+	cfg := &struct {
+		Name    string        `mapstructure:"name"`
+		Timeout time.Duration `mapstructure:"timeout"`
+	}{}
+	assert.NoError(t, config.Unmarshal(cfg))
+	assert.Equal(t, "SERVICE", cfg.Name)
+	assert.Equal(t, time.Minute, cfg.Timeout)
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// IncludeKey is the key with the files included in a map, a file or a list of files:
+//
+//	$include: [db.yaml, cache.yaml]
+//
+// the files are merged in order and then the keys of the map are merged over them
+const IncludeKey = "$include"
+
+// includeTag is the YAML tag of the keys set to the content of a file
+const includeTag = "!include"
+
+// maxIncludeDepth is the maximum number of nested includes
+const maxIncludeDepth = 10
+
+// includeFile is the value of the keys with the `!include` tag
+type includeFile string
+
+// includer resolve the files included in the configs decoded
+type includer struct {
+	// fsys has the files, nil for the OS filesystem
+	fsys fs.FS
+	// stack has the files being included, to detect cycles
+	stack []string
+	// files has all the files included
+	files []string
+	// origins has the origin of the keys set by the files included
+	origins map[string]Origin
+}
+
+// includeSource is the file where the values being resolved are defined
+type includeSource struct {
+	// file is empty for the file resolved by resolveIncludes
+	file  string
+	lines map[string]int
+	// prefix is the key where the file is included
+	prefix []string
+}
+
+// resolveIncludes replace the IncludeKey keys and the `!include` values of the map given
+// with the content of the files, relative to the file given, or to the current directory
+// if the file is empty. the map is modified. the includer returned has the files
+// included and the origins of their keys
+func resolveIncludes(cm ConfigMap, fsys fs.FS, file string) (ConfigMap, *includer, error) {
+	in := &includer{fsys: fsys, origins: make(map[string]Origin)}
+	dir := "."
+	if file != "" {
+		file = in.join(".", file)
+		in.stack = []string{file}
+		dir = in.dir(file)
+	}
+	resolved, err := in.resolveMap(cm, dir, nil, includeSource{})
+	if err != nil {
+		return nil, nil, err
+	}
+	return ConfigMap(resolved), in, nil
+}
+
+// resolveMap resolve the includes of the map given, the files of IncludeKey are
+// merged in order and then the keys of the map are merged over them. the origins
+// are recorded in the same order, so the last value of a key has its origin
+func (in *includer) resolveMap(m map[string]interface{}, dir string, keys []string, source includeSource) (map[string]interface{}, error) {
+	merged := make(map[string]interface{})
+	if files, ok := m[IncludeKey]; ok {
+		delete(m, IncludeKey)
+		paths, err := includePaths(files)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range paths {
+			included, err := in.include(file, dir, keys)
+			if err != nil {
+				return nil, err
+			}
+			includedMap, _ := asMap(included)
+			merged = MergeKeys(merged, includedMap)
+		}
+	}
+	if len(m) == 0 {
+		in.record(keys, source)
+	}
+	for _, key := range sortedKeys(m) {
+		resolved, err := in.resolve(m[key], dir, append(keys[:len(keys):len(keys)], key), source)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = resolved
+	}
+	if len(merged) == 0 {
+		return m, nil
+	}
+	// the keys of the map have precedence over the files included
+	return MergeKeys(merged, m), nil
+}
+
+func (in *includer) resolve(val interface{}, dir string, keys []string, source includeSource) (interface{}, error) {
+	switch v := val.(type) {
+	case includeFile:
+		return in.include(string(v), dir, keys)
+	case []interface{}:
+		if len(v) == 0 {
+			in.record(keys, source)
+		}
+		for i, item := range v {
+			resolved, err := in.resolve(item, dir, append(keys[:len(keys):len(keys)], strconv.Itoa(i)), source)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+	}
+	if m, isMap := asMap(val); isMap {
+		_, hasIncludes := m[IncludeKey]
+		resolved, err := in.resolveMap(m, dir, keys, source)
+		if err != nil || hasIncludes {
+			return resolved, err
+		}
+		// the map is modified, so the type is kept
+		return val, nil
+	}
+	in.record(keys, source)
+	return val, nil
+}
+
+// record set the origin of the key given to the file where it is defined, the keys
+// of the file resolved by resolveIncludes have the origin of the source loaded
+func (in *includer) record(keys []string, source includeSource) {
+	key := buildKey(keys)
+	if source.file == "" {
+		delete(in.origins, key)
+		return
+	}
+	in.origins[key] = Origin{Source: SourceFile, Path: source.file, Line: source.lines[buildKey(keys[len(source.prefix):])]}
+}
+
+// include read and decode the file given, resolving its includes
+func (in *includer) include(file, dir string, keys []string) (interface{}, error) {
+	file = in.join(dir, file)
+	for i, f := range in.stack {
+		if f == file {
+			cycle := append(in.stack[i:len(in.stack):len(in.stack)], file)
+			return nil, fmt.Errorf("include cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if len(in.stack) > maxIncludeDepth {
+		return nil, fmt.Errorf("fail to include %s: more than %d nested includes", file, maxIncludeDepth)
+	}
+	content, err := in.readFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("fail to include %s: %w", file, err)
+	}
+	ext := getFileExt(file)
+	cm, err := decode(content, ext)
+	if err != nil {
+		return nil, fmt.Errorf("fail to include %s: %w", file, err)
+	}
+	in.files = append(in.files, file)
+	source := includeSource{file: file, prefix: keys}
+	if ext == "yaml" || ext == "yml" {
+		source.lines = yamlLines(content)
+	}
+	in.stack = append(in.stack, file)
+	defer func() { in.stack = in.stack[:len(in.stack)-1] }()
+	return in.resolveMap(cm, in.dir(file), keys, source)
+}
+
+func (in *includer) readFile(file string) ([]byte, error) {
+	if in.fsys != nil {
+		return fs.ReadFile(in.fsys, file)
+	}
+	return os.ReadFile(file)
+}
+
+// join return the path of the file relative to the directory given, the absolute
+// paths of the OS filesystem are kept
+func (in *includer) join(dir, file string) string {
+	if in.fsys != nil {
+		return path.Join(dir, file)
+	}
+	if filepath.IsAbs(file) {
+		return filepath.Clean(file)
+	}
+	return filepath.Join(dir, file)
+}
+
+func (in *includer) dir(file string) string {
+	if in.fsys != nil {
+		return path.Dir(file)
+	}
+	return filepath.Dir(file)
+}
+
+// includePaths return the files of the value of IncludeKey
+func includePaths(val interface{}) ([]string, error) {
+	switch v := val.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		paths := make([]string, 0, len(v))
+		for _, item := range v {
+			file, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s value %v, should be a file or a list of files", IncludeKey, val)
+			}
+			paths = append(paths, file)
+		}
+		return paths, nil
+	}
+	return nil, fmt.Errorf("invalid %s value %v, should be a file or a list of files", IncludeKey, val)
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeTempFile(t, dir, "db.yaml", "database:\n  host: localhost\n  port: 5432\n  user: admin\n")
+	writeTempFile(t, dir, "cache.json", `{"cache": {"ttl": "5m"}, "database": {"port": 5433}}`)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "services"), 0755))
+	writeTempFile(t, dir, "services/login.yaml", "$include: ../tls.yaml\nport: 3001\n")
+	writeTempFile(t, dir, "tls.yaml", "tls:\n  enabled: true\n")
+	config := writeTempFile(t, dir, "config.yaml", `
+$include: [db.yaml, cache.json]
+database:
+  host: db.example.com
+services:
+  login: !include services/login.yaml
+`)
+
+	c := New()
+	assert.NoError(t, c.LoadConfigs(config))
+	// the keys of the file have precedence over the files included, merged in order
+	assert.Equal(t, "db.example.com", c.Get("database.host"))
+	assert.Equal(t, float64(5433), c.Get("database.port"))
+	assert.Equal(t, "5m", c.Get("cache.ttl"))
+	assert.Equal(t, 3001, c.Get("services.login.port"))
+	assert.Equal(t, true, c.Get("services.login.tls.enabled"))
+	assert.Nil(t, c.Get(IncludeKey))
+
+	// the origins are the files included
+	assert.Equal(t, Origin{Source: SourceFile, Path: filepath.Join(dir, "db.yaml"), Line: 4}, originOf(c, "database.user"))
+	assert.Equal(t, Origin{Source: SourceFile, Path: filepath.Join(dir, "cache.json")}, originOf(c, "database.port"))
+	assert.Equal(t, Origin{Source: SourceFile, Path: config, Line: 4}, originOf(c, "database.host"))
+	assert.Equal(t, Origin{Source: SourceFile, Path: filepath.Join(dir, "cache.json")}, originOf(c, "cache.ttl"))
+	assert.Equal(t, Origin{Source: SourceFile, Path: filepath.Join(dir, "services/login.yaml"), Line: 2}, originOf(c, "services.login.port"))
+	assert.Equal(t, Origin{Source: SourceFile, Path: filepath.Join(dir, "tls.yaml"), Line: 2}, originOf(c, "services.login.tls.enabled"))
+
+	m, err := ReadFile(config)
+	assert.NoError(t, err)
+	assert.Equal(t, c.Snapshot(), m)
+
+	fsys := fstest.MapFS{
+		"configs/config.json": {Data: []byte(`{"$include": "db.json", "app": {"$include": "app.json"}}`)},
+		"configs/db.json":     {Data: []byte(`{"database": {"host": "localhost"}}`)},
+		"configs/app.json":    {Data: []byte(`{"port": 3001}`)},
+	}
+	c = New()
+	assert.NoError(t, c.LoadFS(fsys, "configs/config.json"))
+	assert.Equal(t, "localhost", c.Get("database.host"))
+	assert.Equal(t, float64(3001), c.Get("app.port"))
+}
+
+func TestWatchIncludes(t *testing.T) {
+	dir := t.TempDir()
+	db := writeTempFile(t, dir, "db.yaml", "db:\n  port: 1\n")
+	config := writeTempFile(t, dir, "c.yaml", "$include: db.yaml\n")
+
+	c := New()
+	assert.NoError(t, c.LoadConfigs(config))
+	changed := make(chan ConfigMap, 1)
+	c.OnChange(func(_, new ConfigMap) { changed <- new })
+	stop := c.WatchConfig(10 * time.Millisecond)
+	defer stop()
+
+	assert.NoError(t, os.WriteFile(db, []byte("db:\n  port: 2\n"), 0644))
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the change of the file included was not loaded")
+	}
+	assert.Equal(t, 2, c.Get("db.port"))
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTempFile(t, dir, "a.yaml", "$include: b.yaml\n")
+	writeTempFile(t, dir, "b.yaml", "b: !include a.yaml\n")
+	_, err := ReadFile(a)
+	assert.EqualError(t, err, "include cycle "+strings.Join([]string{a, filepath.Join(dir, "b.yaml"), a}, " -> "))
+
+	deep := writeTempFile(t, dir, "deep.yaml", "$include: deep.yaml\n")
+	err = New().LoadConfigs(deep)
+	assert.ErrorContains(t, err, "include cycle")
+
+	for i := 0; i <= maxIncludeDepth+1; i++ {
+		writeTempFile(t, dir, "level"+string(rune('a'+i))+".yaml", "$include: level"+string(rune('a'+i+1))+".yaml\n")
+	}
+	_, err = ReadFile(filepath.Join(dir, "levela.yaml"))
+	assert.ErrorContains(t, err, "more than 10 nested includes")
+
+	_, err = ReadReader(strings.NewReader("$include: missing.yaml\n"), "yaml")
+	assert.ErrorContains(t, err, "fail to include missing.yaml")
+	_, err = ReadReader(strings.NewReader("$include: {a: b}\n"), "yaml")
+	assert.EqualError(t, err, "invalid $include value map[a:b], should be a file or a list of files")
+	_, err = ReadReader(strings.NewReader("a: !include [a.yaml]\n"), "yaml")
+	assert.EqualError(t, err, "line 1: !include should be a file path")
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// appendIndex is the segment of a key to append an item to a list, `servers[]` or `servers.-`
+const appendIndex = "-"
+
+// splitKey split a key in `dot-notation` into its segments. the items of the lists
+// can be given as segments or between brackets, so `servers.0.host` and
+// `servers[0].host` are the same key, an empty index (`servers[]`) is appendIndex.
+// segments with dots can be quoted, `hosts."example.com".port`, or escaped,
+// `hosts.example\.com.port`, a backslash escape any character
+func splitKey(key string) []string {
+	segments := make([]string, 0, strings.Count(key, ".")+1)
+	var b strings.Builder
+	// started is true when the current segment has content or was quoted
+	started := false
+	// afterIndex is true after an index, so the next dot don't add an empty segment
+	afterIndex := false
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case c == '\\' && i+1 < len(key):
+			b.WriteByte(key[i+1])
+			i++
+			started, afterIndex = true, false
+		case c == '"' && !started:
+			quoted, end := unquoteSegment(key, i)
+			if end < 0 {
+				// not terminated, the quote is part of the segment
+				b.WriteByte(c)
+			} else {
+				b.WriteString(quoted)
+				i = end
+			}
+			started, afterIndex = true, false
+		case c == '.':
+			if !afterIndex {
+				segments = append(segments, b.String())
+			}
+			b.Reset()
+			started, afterIndex = false, false
+		case c == '[':
+			end := strings.IndexByte(key[i:], ']')
+			if end < 0 {
+				// not an index, keep the rest as it is
+				b.WriteString(key[i:])
+				i = len(key)
+				started, afterIndex = true, false
+				continue
+			}
+			if started {
+				segments = append(segments, b.String())
+			}
+			b.Reset()
+			index := key[i+1 : i+end]
+			if index == "" {
+				index = appendIndex
+			}
+			segments = append(segments, index)
+			i += end
+			started, afterIndex = false, true
+		default:
+			b.WriteByte(c)
+			started, afterIndex = true, false
+		}
+	}
+	if !afterIndex || started {
+		segments = append(segments, b.String())
+	}
+	return segments
+}
+
+// unquoteSegment return the content of the quoted segment starting at the position
+// given and the position of the closing quote, or -1 if the quote is not closed
+func unquoteSegment(key string, start int) (string, int) {
+	var b strings.Builder
+	for i := start + 1; i < len(key); i++ {
+		switch key[i] {
+		case '\\':
+			if i+1 < len(key) {
+				i++
+				b.WriteByte(key[i])
+			}
+		case '"':
+			return b.String(), i
+		default:
+			b.WriteByte(key[i])
+		}
+	}
+	return "", -1
+}
+
+// buildKey join the segments given into a key in `dot-notation`, quoting the
+// segments that are empty or have dots, brackets, quotes or backslashes,
+// so splitKey return the same segments
+func buildKey(segments []string) string {
+	var b strings.Builder
+	for i, segment := range segments {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		if segment != "" && !strings.ContainsAny(segment, `.[]"\`) {
+			b.WriteString(segment)
+			continue
+		}
+		b.WriteByte('"')
+		for j := 0; j < len(segment); j++ {
+			if segment[j] == '"' || segment[j] == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(segment[j])
+		}
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// normalizeKey return the key given as built by buildKey,
+// so the same key written in different ways can be compared
+func normalizeKey(key string) string {
+	return buildKey(splitKey(key))
+}
+
+// resolveKey return the keys given with the appendIndex segments replaced by
+// the index the item would have in the map given, used to name the keys set
+func resolveKey(m map[string]interface{}, keys []string) []string {
+	resolved := make([]string, len(keys))
+	var current interface{} = m
+	for i, key := range keys {
+		resolved[i] = key
+		if _, isMap := asMap(current); key == appendIndex && !isMap {
+			list, _ := current.([]interface{})
+			resolved[i] = strconv.Itoa(len(list))
+			current = nil
+			continue
+		}
+		current, _ = lookupIn(current, []string{key})
+	}
+	return resolved
+}
+
+// resolveAppends is resolveKey only for the lists of the map given, the appendIndex
+// segments of the lists not created yet are kept, so they are created as lists
+func resolveAppends(m map[string]interface{}, keys []string) []string {
+	resolved := resolveKey(m, keys)
+	var current interface{} = m
+	for i, key := range keys {
+		if _, isList := current.([]interface{}); key == appendIndex && !isList {
+			resolved[i] = appendIndex
+		}
+		current, _ = lookupIn(current, resolved[i:i+1])
+	}
+	return resolved
+}
+
+// listIndex return the index of the list given for the segment, length for appendIndex,
+// and false if the segment is not an index
+func listIndex(segment string, length int) (int, bool) {
+	if segment == appendIndex {
+		return length, true
+	}
+	index, err := strconv.Atoi(segment)
+	if err != nil || index < 0 || strconv.Itoa(index) != segment {
+		return 0, false
+	}
+	return index, true
+}
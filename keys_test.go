@@ -0,0 +1,67 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitKey(t *testing.T) {
+	tests := map[string][]string{
+		"":                 {""},
+		"a":                {"a"},
+		"a.b.c":            {"a", "b", "c"},
+		"servers.0.host":   {"servers", "0", "host"},
+		"servers[0].host":  {"servers", "0", "host"},
+		"servers[1][2]":    {"servers", "1", "2"},
+		"[0].host":         {"0", "host"},
+		"servers[]":        {"servers", "-"},
+		"servers[].host":   {"servers", "-", "host"},
+		"servers[0":        {"servers[0"},
+		"a..b":             {"a", "", "b"},
+		"matrix.[1].value": {"matrix", "1", "value"},
+	}
+	for key, want := range tests {
+		if got := splitKey(key); !reflect.DeepEqual(got, want) {
+			t.Fatalf("splitKey(%q) = %#v, want %#v", key, got, want)
+		}
+	}
+}
+
+func TestSplitKeyQuoted(t *testing.T) {
+	tests := map[string][]string{
+		`hosts."example.com".port`: {"hosts", "example.com", "port"},
+		`hosts.example\.com.port`:  {"hosts", "example.com", "port"},
+		`hosts."a\"b".port`:        {"hosts", `a"b`, "port"},
+		`hosts."a\\b"`:             {"hosts", `a\b`},
+		`hosts."".port`:            {"hosts", "", "port"},
+		`hosts."[0]"`:              {"hosts", "[0]"},
+		`hosts."example.com"[0]`:   {"hosts", "example.com", "0"},
+		`hosts.a"b`:                {"hosts", `a"b`},
+		`hosts."unterminated.port`: {"hosts", `"unterminated`, "port"},
+		`hosts.example\[0\]`:       {"hosts", "example[0]"},
+	}
+	for key, want := range tests {
+		if got := splitKey(key); !reflect.DeepEqual(got, want) {
+			t.Fatalf("splitKey(%q) = %#v, want %#v", key, got, want)
+		}
+	}
+}
+
+func TestBuildKey(t *testing.T) {
+	tests := map[string][]string{
+		"a.b.c":                    {"a", "b", "c"},
+		`hosts."example.com".port`: {"hosts", "example.com", "port"},
+		`hosts."a\"b\\c"`:          {"hosts", `a"b\c`},
+		`hosts."".port`:            {"hosts", "", "port"},
+		`servers.0."[1]"`:          {"servers", "0", "[1]"},
+	}
+	for want, segments := range tests {
+		key := buildKey(segments)
+		if key != want {
+			t.Fatalf("buildKey(%#v) = %q, want %q", segments, key, want)
+		}
+		if got := splitKey(key); !reflect.DeepEqual(got, segments) {
+			t.Fatalf("splitKey(buildKey(%#v)) = %#v", segments, got)
+		}
+	}
+}
@@ -1,74 +1,44 @@
 package config
 
 import (
-	"strings"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
 
 	"github.com/mitchellh/mapstructure"
 )
 
-// MergeKeys merge 2 ConfigMap given
+// MergeKeys merge 2 ConfigMap given, the lists and values of m2 replace the ones
+// of m1, use MergeKeysWith to merge the lists with other strategies
 func MergeKeys(m1, m2 ConfigMap) map[string]interface{} {
-	for key, m2Val := range m2 {
-		// first we validate if key exist
-		m1Val, ok := m1[key]
-		if !ok {
-			// If key no exist , add it and continue
-			m1[key] = m2Val
-			continue
-		}
-		switch v := m1Val.(type) {
-		case map[string]interface{}:
-			// Recursive Call
-			m1[key] = MergeKeys(v, m2Val.(ConfigMap))
-		default:
-			m1[key] = m2Val
-		}
-	}
-	return m1
+	// replacing never has conflicts
+	merged, _ := MergeKeysWith(m1, m2, MergeOptions{})
+	return merged
 }
 
-// MergeEnvVar merge Env variables into placeholders
+// MergeEnvVar merge Env variables into placeholders,
+// placeholders can be part of a larger string ("http://${HOST}:${PORT}"),
+// have a default value (${VAR:-default}) or be escaped ($${VAR}) for literal text.
+// required placeholders (${VAR:?message}) without value are replaced by an empty string,
+// Config.LoadConfigs return an error instead
 func MergeEnvVar(m, envVars ConfigMap) map[string]interface{} {
-	for key, val := range m {
-		switch value := val.(type) {
-		case ConfigMap:
-			// Recursive Call
-			m[key] = MergeEnvVar(value, envVars)
-		case map[string]interface{}:
-			// Recursive Call
-			m[key] = MergeEnvVar(value, envVars)
-		case string:
-			if strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") {
-				envValue := ""
-				if len(envVars) > 0 {
-					pvalue := GetValue(
-						envVars,
-						[]string{strings.TrimSuffix(strings.TrimPrefix(value, "${"), "}")},
-					)
-					if pvalue != nil {
-						// cast to string as we know all the values from env are strings
-						envValue = pvalue.(string)
-					}
-				}
-				m[key] = envValue
-			}
-		default:
-			continue
-		}
-	}
-	return m
+	merged, _ := mergeEnvVar(m, envVars, nil, nil, nil)
+	return merged
 }
 
-// Flatten  is a init wrapper for flatten
+// Flatten  is a init wrapper for flatten,
+// the items of the lists are added with their index, like `servers.0.host`
 func Flatten(m map[string]interface{}) map[string]interface{} {
 	out := make(map[string]interface{})
 
-	out = flatten(m, nil, out)
+	out = flatten(m, nil, out, true)
 	return out
 }
 
-// flatten  is a recursive function to convert map[string]interface{} into a dot-notation
-func flatten(m map[string]interface{}, keys []string, out map[string]interface{}) map[string]interface{} {
+// flatten  is a recursive function to convert map[string]interface{} into a dot-notation,
+// when indexLists is false the lists are added as values
+func flatten(m map[string]interface{}, keys []string, out map[string]interface{}, indexLists bool) map[string]interface{} {
 	for key, val := range m {
 		// Copy the incoming key paths into a new map
 		// and append the current key in the iteration.
@@ -77,99 +47,309 @@ func flatten(m map[string]interface{}, keys []string, out map[string]interface{}
 		keyPaths = append(keyPaths, keys...)
 		// append the new key
 		keyPaths = append(keyPaths, key)
+		out = flattenValue(val, keyPaths, out, indexLists)
+	}
+	return out
+}
+
+func flattenValue(val interface{}, keyPaths []string, out map[string]interface{}, indexLists bool) map[string]interface{} {
+	if list, isList := val.([]interface{}); isList && indexLists && len(list) > 0 {
+		for i, item := range list {
+			out = flattenValue(item, append(keyPaths[:len(keyPaths):len(keyPaths)], strconv.Itoa(i)), out, indexLists)
+		}
+		return out
+	}
+	// verify the type of the current value
+	cur, isMap := asMap(val)
+	// Empty map. only add as is it
+	if !isMap || len(cur) == 0 {
+		newKey := buildKey(keyPaths)
+		out[newKey] = val
+		return out
+	}
+	// Recursive call if value is not empty
+	return flatten(cur, keyPaths, out, indexLists)
+}
+
+// Unflatten is a function to convert a map with keys in `dot-notation` into nested maps,
+// the opposite of Flatten, so flat sources (env variables, properties files, key/value
+// stores...) can be merged with MergeKeys. the keys are set in order, so when a key is
+// a value and the parent of other keys, like `a` and `a.b`, the nested keys win. the maps
+// of the keys with all the segments from 0 to n are converted to lists, so the lists
+// flattened are restored, the maps given as values are kept
+func Unflatten(m map[string]interface{}) ConfigMap {
+	out, _ := unflatten(m)
+	return out
+}
+
+// UnflattenStrict is Unflatten returning an error with all the keys
+// that are a value and the parent of other keys, or are defined twice
+func UnflattenStrict(m map[string]interface{}) (ConfigMap, error) {
+	out, conflicts := unflatten(m)
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("fail to unflatten keys: %w", errors.Join(conflicts...))
+	}
+	return out, nil
+}
 
-		// verify the type of the current value
-		switch cur := val.(type) {
-		case map[string]interface{}:
-			// Empty map. only add as is it
-			if len(cur) == 0 {
-				newKey := strings.Join(keyPaths, ".")
-				out[newKey] = val
-				continue
+// unflatten convert the flat map given into nested maps, returning the conflicts found
+func unflatten(m map[string]interface{}) (ConfigMap, []error) {
+	out := make(ConfigMap)
+	// values has the keys set to a value that is not a map
+	values := make(map[string]string)
+	// parents has the keys with nested keys, by the first nested key
+	parents := make(map[string]string)
+	seen := make(map[string]string)
+	var conflicts []error
+	// the parents are set before their nested keys, so the nested keys win
+	sorted := sortedKeys(m)
+	segments := make(map[string][]string, len(m))
+	for _, key := range sorted {
+		segments[key] = splitKey(key)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(segments[sorted[i]]) < len(segments[sorted[j]])
+	})
+	for _, key := range sorted {
+		keys := segments[key]
+		normalized := buildKey(keys)
+		if previous, ok := seen[normalized]; ok {
+			conflicts = append(conflicts, fmt.Errorf("key %s is defined twice, as %s", previous, key))
+		}
+		seen[normalized] = key
+		for i := 1; i < len(keys); i++ {
+			parentKey := buildKey(keys[:i])
+			if parent, ok := values[parentKey]; ok {
+				conflicts = append(conflicts, fmt.Errorf("key %s is a value and the parent of %s", parent, key))
+			}
+			if _, ok := parents[parentKey]; !ok {
+				parents[parentKey] = key
 			}
+		}
+		if _, isMap := asMap(m[key]); !isMap {
+			values[normalized] = key
+			if child, ok := parents[normalized]; ok {
+				conflicts = append(conflicts, fmt.Errorf("key %s is a value and the parent of %s", key, child))
+			}
+		}
+		SetValue(out, keys, copyValue(m[key]))
+	}
+	// the root is always a map
+	for key, val := range out {
+		out[key] = restoreLists(val, []string{key}, parents)
+	}
+	return out, conflicts
+}
 
-			// Recursive call if value is not empty
-			out = flatten(cur, keyPaths, out)
-		default:
-			newKey := strings.Join(keyPaths, ".")
-			out[newKey] = val
+// restoreLists convert the maps with the keys from 0 to n into lists, only the maps
+// built from the segments of the flattened keys, the parents given, are converted,
+// so the maps of the values keep their keys
+func restoreLists(val interface{}, keys []string, parents map[string]string) interface{} {
+	m, isMap := asMap(val)
+	if !isMap {
+		return val
+	}
+	for key, item := range m {
+		m[key] = restoreLists(item, append(keys[:len(keys):len(keys)], key), parents)
+	}
+	if _, isParent := parents[buildKey(keys)]; len(m) == 0 || !isParent {
+		return val
+	}
+	list := make([]interface{}, len(m))
+	for key, item := range m {
+		index, ok := listIndex(key, len(m))
+		if !ok || index >= len(m) || key == appendIndex {
+			return val
 		}
+		list[index] = item
 	}
-	return out
+	return list
 }
 
-// GetValue is a function to search recursively a key in a map[string]interface{}
+// GetValue is a function to search recursively a key in a map[string]interface{},
+// the items of the lists are found by their index
 func GetValue(m map[string]interface{}, keysToFind []string) interface{} {
-	lenkeysToFind := len(keysToFind)
-	// if keysToFind is empty we return empty string
-	if lenkeysToFind < 1 {
-		return nil
+	val, _ := lookupValue(m, keysToFind)
+	return val
+}
+
+// lookupValue search recursively a key in a map[string]interface{},
+// returning false if the key don't exist
+func lookupValue(m map[string]interface{}, keysToFind []string) (interface{}, bool) {
+	// if keysToFind is empty there is nothing to find
+	if len(keysToFind) < 1 {
+		return nil, false
+	}
+	return lookupIn(m, keysToFind)
+}
+
+func lookupIn(val interface{}, keysToFind []string) (interface{}, bool) {
+	// if there are no more keys to find, this is the value
+	if len(keysToFind) < 1 {
+		return val, true
 	}
 	// takes the first postion
 	keyVal := keysToFind[0]
 	next := keysToFind[1:]
-	val, ok := m[keyVal]
-	if !ok {
-		return nil
-	}
-	// validate the type, if still a map[string]interface{}
+	// validate the type, if still a map[string]interface{} or a list
 	// we should do a recursive call
-	switch v := val.(type) {
-	case map[string]interface{}:
+	if v, ok := asMap(val); ok {
+		item, ok := v[keyVal]
+		if !ok {
+			return nil, false
+		}
 		// Recusive call
-		return GetValue(v, next)
-	default:
-		// if 'next' has keys inside means, the key don't exist
-		if len(next) < 1 {
-			return val
+		return lookupIn(item, next)
+	}
+	if list, ok := val.([]interface{}); ok {
+		index, ok := listIndex(keyVal, len(list))
+		if !ok || index >= len(list) {
+			return nil, false
 		}
+		return lookupIn(list[index], next)
 	}
-	return nil
+	// 'next' has keys inside, means the key don't exist
+	return nil, false
 }
 
-// SetValue is a function to search recursively a key in a map[string]interface{} and add it with a set of keys given
+// SetValue is a function to search recursively a key in a map[string]interface{} and add it with a set of keys given.
+// the items of the lists are set by their index, the index `-` or the length of the list
+// append the value to the list and the indexes beyond the length are ignored
 func SetValue(m map[string]interface{}, keysToFind []string, value interface{}) map[string]interface{} {
-	lenkeysToFind := len(keysToFind)
-	// if keysToFind is empty we return empty string
-	if lenkeysToFind < 1 {
+	// if keysToFind is empty there is nothing to set
+	if len(keysToFind) < 1 {
 		return m
 	}
+	setIn(m, keysToFind, value)
+	return m
+}
+
+// setIn set the value in the map or list given, returning the updated one
+func setIn(container interface{}, keysToFind []string, value interface{}) interface{} {
+	// if there are no more keys, add or update the value
+	if len(keysToFind) < 1 {
+		return value
+	}
 	// takes the first position
 	keyVal := keysToFind[0]
 	next := keysToFind[1:]
-	val, ok := m[keyVal]
-	if !ok {
-		// by default set the key as the value
-		val = value
-		// if key not exist, but have more keys to search
-		if len(next) > 0 {
-			val = make(map[string]interface{})
+	if v, ok := asMap(container); ok {
+		// Recusrive call
+		v[keyVal] = setIn(v[keyVal], next, value)
+		return container
+	}
+	if list, ok := container.([]interface{}); ok {
+		if index, ok := listIndex(keyVal, len(list)); ok {
+			switch {
+			case index == len(list):
+				return append(list, setIn(nil, next, value))
+			case index < len(list):
+				list[index] = setIn(list[index], next, value)
+			}
+			// the indexes beyond the length are ignored, so the list is not padded
+			return list
+		}
+	}
+	// not a map or a list with the index, override the current
+	// value with a new list to append or a new nested map[string]interface{}
+	if keyVal == appendIndex && container == nil {
+		return []interface{}{setIn(nil, next, value)}
+	}
+	return map[string]interface{}{keyVal: setIn(nil, next, value)}
+}
+
+// deleteValue remove the key given from the map, the items of the lists
+// are removed by their index, moving the next items
+func deleteValue(m map[string]interface{}, keys []string) map[string]interface{} {
+	if len(keys) < 1 {
+		return m
+	}
+	deleteIn(m, keys)
+	return m
+}
+
+// deleteIn remove the key from the map or list given, returning the updated one
+func deleteIn(container interface{}, keys []string) interface{} {
+	keyVal := keys[0]
+	next := keys[1:]
+	if v, ok := asMap(container); ok {
+		item, ok := v[keyVal]
+		if !ok {
+			return container
+		}
+		if len(next) < 1 {
+			delete(v, keyVal)
+		} else {
+			v[keyVal] = deleteIn(item, next)
 		}
+		return container
+	}
+	if list, ok := container.([]interface{}); ok {
+		index, ok := listIndex(keyVal, len(list))
+		if !ok || index >= len(list) {
+			return container
+		}
+		if len(next) < 1 {
+			return append(list[:index:index], list[index+1:]...)
+		}
+		list[index] = deleteIn(list[index], next)
+	}
+	return container
+}
+
+// copyMap return a deep copy of the map given, nested maps and slices are copied too
+func copyMap(m map[string]interface{}) ConfigMap {
+	out := make(ConfigMap, len(m))
+	for key, val := range m {
+		out[key] = copyValue(val)
 	}
-	// validate the type, if still a map[string]interface
-	// we should do recursion call, in not validate if still
-	// theres keys to find
+	return out
+}
+
+// copyValue return a deep copy of maps and slices, any other value is returned as it is
+func copyValue(val interface{}) interface{} {
 	switch v := val.(type) {
+	case ConfigMap:
+		return copyMap(v)
 	case map[string]interface{}:
-		// Recusrive call
-		m[keyVal] = SetValue(v, next, value)
-	default:
-		// if still has more keys, override the current value with
-		// with a new nested map[string]interface{}
-		if len(next) > 0 {
-			val = SetValue(make(map[string]interface{}), next, value)
+		return map[string]interface{}(copyMap(v))
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = copyValue(item)
 		}
-		// create the new key in the map
-		m[keyVal] = val
+		return out
+	}
+	return val
+}
 
+// sortedKeys return the keys of the map sorted
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
-	return m
+	sort.Strings(keys)
+	return keys
+}
+
+// asMap return the value as a map[string]interface{} if is a map,
+// decoders can return nested maps as ConfigMap or as map[string]interface{}
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case ConfigMap:
+		return m, true
+	}
+	return nil, false
 }
 
 // mapStructDecoder function convert a map[string]interface{} into a struct using mapstructure from external package
-func mapStructureDecoder(configMap ConfigMap, out *interface{}) error {
+func mapStructureDecoder(configMap ConfigMap, out *interface{}, metadata *mapstructure.Metadata, hook mapstructure.DecodeHookFunc) error {
 	config := &mapstructure.DecoderConfig{
-		Metadata:         nil,
+		DecodeHook:       hook,
+		Metadata:         metadata,
 		Result:           out,
 		WeaklyTypedInput: true,
 	}
@@ -155,3 +155,233 @@ func TestMergeKeys_ReplaceWithMap(t *testing.T) {
 		t.Fatalf("MergeKeys(replace with map) = %#v, want %#v", got, want)
 	}
 }
+
+func TestGetValue_ReturnNestedMap(t *testing.T) {
+	in := map[string]interface{}{
+		"a": ConfigMap{
+			"b": map[string]interface{}{"c": 1},
+		},
+	}
+	want := map[string]interface{}{"c": 1}
+
+	got := GetValue(in, []string{"a", "b"})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetValue(nested map) = %#v, want %#v", got, want)
+	}
+	if got := GetValue(in, []string{"a", "b", "c", "d"}); got != nil {
+		t.Fatalf("GetValue(missing key) = %#v, want nil", got)
+	}
+}
+
+func TestSetValue_UpdateExisting(t *testing.T) {
+	in := map[string]interface{}{
+		"a": "old",
+		"b": map[string]interface{}{"c": "old"},
+	}
+	want := map[string]interface{}{
+		"a": "new",
+		"b": map[string]interface{}{"c": "new", "d": "added"},
+	}
+
+	SetValue(in, []string{"a"}, "new")
+	SetValue(in, []string{"b", "c"}, "new")
+	SetValue(in, []string{"b", "d"}, "added")
+	if !reflect.DeepEqual(in, want) {
+		t.Fatalf("SetValue(update existing) = %#v, want %#v", in, want)
+	}
+}
+
+func TestMergeKeys_MergeNestedMixedMapTypes(t *testing.T) {
+	m1 := ConfigMap{
+		"s": ConfigMap{
+			"k1": "v1",
+		},
+	}
+	m2 := ConfigMap{
+		"s": map[string]interface{}{
+			"k2": "v2",
+		},
+	}
+
+	got := MergeKeys(m1, m2)
+	want := map[string]interface{}{
+		"s": map[string]interface{}{
+			"k1": "v1",
+			"k2": "v2",
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MergeKeys(merge nested mixed types) = %#v, want %#v", got, want)
+	}
+}
+
+func TestFlatten_IndexLists(t *testing.T) {
+	in := map[string]interface{}{
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a"},
+			ConfigMap{"host": "b", "ports": []interface{}{80, 443}},
+		},
+		"empty": []interface{}{},
+	}
+	want := map[string]interface{}{
+		"servers.0.host":    "a",
+		"servers.1.host":    "b",
+		"servers.1.ports.0": 80,
+		"servers.1.ports.1": 443,
+		"empty":             []interface{}{},
+	}
+
+	got := Flatten(in)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Flatten(lists) = %#v, want %#v", got, want)
+	}
+}
+
+func TestGetValue_ListIndex(t *testing.T) {
+	in := map[string]interface{}{
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a"},
+			ConfigMap{"ports": []interface{}{80, 443}},
+		},
+	}
+
+	if got := GetValue(in, []string{"servers", "0", "host"}); got != "a" {
+		t.Fatalf("GetValue(servers.0.host) = %#v, want %#v", got, "a")
+	}
+	if got := GetValue(in, []string{"servers", "1", "ports", "1"}); got != 443 {
+		t.Fatalf("GetValue(servers.1.ports.1) = %#v, want %#v", got, 443)
+	}
+	for _, keys := range [][]string{{"servers", "2"}, {"servers", "-1"}, {"servers", "01"}, {"servers", "host"}} {
+		if got := GetValue(in, keys); got != nil {
+			t.Fatalf("GetValue(%v) = %#v, want nil", keys, got)
+		}
+	}
+}
+
+func TestSetValue_ListIndex(t *testing.T) {
+	in := map[string]interface{}{
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a"},
+		},
+	}
+	want := map[string]interface{}{
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a", "port": 80},
+			map[string]interface{}{"host": "b"},
+			"c",
+		},
+		"tags": []interface{}{"new"},
+	}
+
+	SetValue(in, []string{"servers", "0", "port"}, 80)
+	SetValue(in, []string{"servers", "-", "host"}, "b")
+	SetValue(in, []string{"servers", "2"}, "c")
+	// the indexes beyond the length are ignored
+	SetValue(in, []string{"servers", "1000000000"}, "d")
+	SetValue(in, []string{"servers", "4", "host"}, "e")
+	SetValue(in, []string{"tags", "-"}, "new")
+	if !reflect.DeepEqual(in, want) {
+		t.Fatalf("SetValue(list index) = %#v, want %#v", in, want)
+	}
+}
+
+func TestFlatten_QuoteKeysWithDots(t *testing.T) {
+	in := map[string]interface{}{
+		"hosts": map[string]interface{}{
+			"example.com": map[string]interface{}{"port": 443},
+		},
+	}
+	want := map[string]interface{}{
+		`hosts."example.com".port`: 443,
+	}
+
+	got := Flatten(in)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Flatten(keys with dots) = %#v, want %#v", got, want)
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	in := map[string]interface{}{
+		"a":                        "b",
+		"c.d":                      "e",
+		`hosts."example.com".port`: 443,
+		`hosts.local\.dev.port`:    80,
+		"servers.0.host":           "a",
+		"servers.1.host":           "b",
+		"ports.1":                  443,
+		"empty":                    []interface{}{},
+	}
+	want := ConfigMap{
+		"a": "b",
+		"c": map[string]interface{}{"d": "e"},
+		"hosts": map[string]interface{}{
+			"example.com": map[string]interface{}{"port": 443},
+			"local.dev":   map[string]interface{}{"port": 80},
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a"},
+			map[string]interface{}{"host": "b"},
+		},
+		// not all the indexes from 0, so is a map
+		"ports": map[string]interface{}{"1": 443},
+		"empty": []interface{}{},
+	}
+
+	got := Unflatten(in)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unflatten() = %#v, want %#v", got, want)
+	}
+	if got := Unflatten(Flatten(want)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unflatten(Flatten()) = %#v, want %#v", got, want)
+	}
+
+	// the maps given as values are not converted to lists
+	in = map[string]interface{}{
+		"priorities":   map[string]interface{}{"0": "low", "1": "high"},
+		"levels.names": map[string]interface{}{"0": "debug"},
+	}
+	want = ConfigMap{
+		"priorities": map[string]interface{}{"0": "low", "1": "high"},
+		"levels":     map[string]interface{}{"names": map[string]interface{}{"0": "debug"}},
+	}
+	if got := Unflatten(in); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unflatten(map values) = %#v, want %#v", got, want)
+	}
+}
+
+func TestUnflattenStrict(t *testing.T) {
+	got, err := UnflattenStrict(map[string]interface{}{"a.b": 1, "a.c": 2})
+	if err != nil {
+		t.Fatalf("UnflattenStrict() error = %v", err)
+	}
+	want := ConfigMap{"a": map[string]interface{}{"b": 1, "c": 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("UnflattenStrict() = %#v, want %#v", got, want)
+	}
+
+	in := map[string]interface{}{
+		"a":      1,
+		"a.b":    2,
+		`x."y"`:  3,
+		"x.y":    4,
+		"m":      map[string]interface{}{},
+		"m.n":    5,
+		`p\.q.r`: 6,
+		`"p.q"`:  7,
+	}
+	_, err = UnflattenStrict(in)
+	wantErr := "fail to unflatten keys: " +
+		"key a is a value and the parent of a.b\n" +
+		"key \"p.q\" is a value and the parent of p\\.q.r\n" +
+		"key x.\"y\" is defined twice, as x.y"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("UnflattenStrict(conflicts) error = %v, want %s", err, wantErr)
+	}
+	// the nested keys win
+	got = Unflatten(in)
+	if got["a"].(map[string]interface{})["b"] != 2 {
+		t.Fatalf("Unflatten(conflicts) = %#v, want nested key a.b", got)
+	}
+}
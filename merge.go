@@ -0,0 +1,315 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// MergeStrategy is how a list is merged with the previous list of the same key
+type MergeStrategy int
+
+const (
+	// MergeReplace replace the previous list, the default
+	MergeReplace MergeStrategy = iota
+	// MergeAppend append the items to the previous list
+	MergeAppend
+	// MergeAppendUnique append the items not found in the previous list
+	MergeAppendUnique
+	// MergeByKey merge the items with the same value in the ItemKey of the rule,
+	// like `name`, the other items are appended
+	MergeByKey
+)
+
+func (s MergeStrategy) String() string {
+	switch s {
+	case MergeReplace:
+		return "replace"
+	case MergeAppend:
+		return "append"
+	case MergeAppendUnique:
+		return "append-unique"
+	case MergeByKey:
+		return "merge-by-key"
+	}
+	return fmt.Sprintf("MergeStrategy(%d)", int(s))
+}
+
+// MergeRule is the strategy to merge the lists of a key
+type MergeRule struct {
+	Strategy MergeStrategy
+	// ItemKey is the key of the items compared by MergeByKey
+	ItemKey string
+}
+
+// MergeOptions configure how MergeKeysWith merge the lists
+type MergeOptions struct {
+	// Lists is the rule for the lists without a rule in Keys
+	Lists MergeRule
+	// Keys has the rules by key in `dot-notation`, like `plugins`
+	Keys map[string]MergeRule
+	// Strict return a conflict when a map or a list is merged with a value
+	// of another type, instead of replacing it
+	Strict bool
+	// DeleteNulls remove the keys set to null, like `key: ~`,
+	// instead of setting them to nil
+	DeleteNulls bool
+}
+
+// Delete is the value of the keys with the `!delete` YAML tag, merging
+// it with MergeKeys or MergeKeysWith remove the key from the map
+var Delete interface{} = deletion{}
+
+// deleteTag is the YAML tag of the keys to delete
+const deleteTag = "!delete"
+
+type deletion struct{}
+
+func (deletion) String() string {
+	return deleteTag
+}
+
+func isDelete(v interface{}) bool {
+	_, ok := v.(deletion)
+	return ok
+}
+
+// MergeConflictError is returned when the value of a key can't be merged
+type MergeConflictError struct {
+	// Key in `dot-notation`
+	Key    string
+	Reason string
+}
+
+func (e *MergeConflictError) Error() string {
+	return fmt.Sprintf("merge conflict at key %s: %s", e.Key, e.Reason)
+}
+
+// MergeKeysWith merge the ConfigMap m2 into m1 as MergeKeys, merging the lists with
+// the strategies of the options given. all the conflicts are returned joined, the keys
+// with a conflict keep the previous value
+func MergeKeysWith(m1, m2 ConfigMap, opts MergeOptions) (map[string]interface{}, error) {
+	merged, _, err := mergeKeysWith(m1, m2, opts)
+	return merged, err
+}
+
+// mergeKeysWith merge the maps given, returning the keys of the items of the
+// lists of m2 moved to another index, by their key in m2
+func mergeKeysWith(m1, m2 ConfigMap, opts MergeOptions) (map[string]interface{}, map[string]string, error) {
+	if m1 == nil {
+		m1 = make(ConfigMap)
+	}
+	mg := &merger{opts: opts, rules: make(map[string]MergeRule, len(opts.Keys))}
+	for key, rule := range opts.Keys {
+		mg.rules[normalizeKey(key)] = rule
+	}
+	mg.mergeMaps(m1, m2, nil)
+	return m1, mg.moved, errors.Join(mg.conflicts...)
+}
+
+type merger struct {
+	opts      MergeOptions
+	rules     map[string]MergeRule
+	moved     map[string]string
+	conflicts []error
+}
+
+func (mg *merger) mergeMaps(m1, m2 map[string]interface{}, path []string) {
+	for _, key := range sortedKeys(m2) {
+		if mg.deletes(m2[key]) {
+			delete(m1, key)
+			continue
+		}
+		val, ok := m1[key]
+		if !ok {
+			m1[key] = mg.clean(m2[key])
+			continue
+		}
+		m1[key] = mg.mergeValue(val, m2[key], append(path[:len(path):len(path)], key))
+	}
+}
+
+func (mg *merger) mergeValue(old, val interface{}, path []string) interface{} {
+	// nil values always replace, so a key can be cleared
+	if old == nil || val == nil {
+		return mg.clean(val)
+	}
+	m1, isMap1 := asMap(old)
+	m2, isMap2 := asMap(val)
+	if isMap1 && isMap2 {
+		mg.mergeMaps(m1, m2, path)
+		return m1
+	}
+	l1, isList1 := old.([]interface{})
+	l2, isList2 := val.([]interface{})
+	rule, hasRule := mg.rules[buildKey(path)]
+	if !hasRule {
+		rule = mg.opts.Lists
+	}
+	if rule.Strategy != MergeReplace && (isList2 || hasRule) {
+		if !isList1 || !isList2 {
+			mg.conflict(path, "strategy %s can't merge %s into %s", rule.Strategy, typeName(val), typeName(old))
+			return old
+		}
+		return mg.mergeLists(l1, l2, path, rule)
+	}
+	if mg.opts.Strict && (isMap1 != isMap2 || isList1 != isList2) {
+		mg.conflict(path, "can't merge %s into %s", typeName(val), typeName(old))
+		return old
+	}
+	return mg.clean(val)
+}
+
+func (mg *merger) mergeLists(l1, l2 []interface{}, path []string, rule MergeRule) interface{} {
+	out := l1[:len(l1):len(l1)]
+	switch rule.Strategy {
+	case MergeAppend:
+		for i, item := range mg.clean(l2).([]interface{}) {
+			mg.move(path, i, len(out))
+			out = append(out, item)
+		}
+	case MergeAppendUnique:
+		for i, item := range mg.clean(l2).([]interface{}) {
+			j := indexOf(out, func(v interface{}) bool { return reflect.DeepEqual(v, item) })
+			if j < 0 {
+				j = len(out)
+				out = append(out, item)
+			}
+			mg.move(path, i, j)
+		}
+	case MergeByKey:
+		if rule.ItemKey == "" {
+			mg.conflict(path, "strategy %s needs an item key", rule.Strategy)
+			return l1
+		}
+		for i, item := range l2 {
+			itemMap, _ := asMap(item)
+			id, ok := itemMap[rule.ItemKey]
+			if !ok {
+				mg.conflict(path, "item %d has no key %s", i, rule.ItemKey)
+				continue
+			}
+			j := indexOf(out, func(v interface{}) bool {
+				m, _ := asMap(v)
+				other, ok := m[rule.ItemKey]
+				return ok && reflect.DeepEqual(other, id)
+			})
+			if j < 0 {
+				j = len(out)
+				out = append(out, mg.clean(item))
+			} else {
+				out[j] = mg.mergeValue(out[j], item, append(path[:len(path):len(path)], strconv.Itoa(j)))
+			}
+			mg.move(path, i, j)
+		}
+	default:
+		mg.conflict(path, "unknown merge strategy %s", rule.Strategy)
+		return l1
+	}
+	return out
+}
+
+// deletes return true if the value given remove the key
+func (mg *merger) deletes(val interface{}) bool {
+	return isDelete(val) || (val == nil && mg.opts.DeleteNulls)
+}
+
+// clean return the value given without the keys that are removed, and without
+// the Delete items of the lists. the value is copied if something is removed
+func (mg *merger) clean(val interface{}) interface{} {
+	if !mg.hasDeletes(val) {
+		return val
+	}
+	return mg.removeDeletes(copyValue(val))
+}
+
+func (mg *merger) hasDeletes(val interface{}) bool {
+	if m, isMap := asMap(val); isMap {
+		for _, item := range m {
+			if mg.deletes(item) || mg.hasDeletes(item) {
+				return true
+			}
+		}
+	}
+	if list, isList := val.([]interface{}); isList {
+		for _, item := range list {
+			if isDelete(item) || mg.hasDeletes(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (mg *merger) removeDeletes(val interface{}) interface{} {
+	if m, isMap := asMap(val); isMap {
+		for key, item := range m {
+			if mg.deletes(item) {
+				delete(m, key)
+				continue
+			}
+			m[key] = mg.removeDeletes(item)
+		}
+	}
+	if list, isList := val.([]interface{}); isList {
+		out := list[:0]
+		for _, item := range list {
+			if !isDelete(item) {
+				out = append(out, mg.removeDeletes(item))
+			}
+		}
+		return out
+	}
+	return val
+}
+
+// move record that the item of the list in the path given is moved to another index
+func (mg *merger) move(path []string, from, to int) {
+	if from == to {
+		return
+	}
+	if mg.moved == nil {
+		mg.moved = make(map[string]string)
+	}
+	keyPath := append(path[:len(path):len(path)], strconv.Itoa(from))
+	mg.moved[buildKey(keyPath)] = buildKey(append(path[:len(path):len(path)], strconv.Itoa(to)))
+}
+
+func (mg *merger) conflict(path []string, format string, args ...interface{}) {
+	mg.conflicts = append(mg.conflicts, &MergeConflictError{Key: buildKey(path), Reason: fmt.Sprintf(format, args...)})
+}
+
+// movedKey return the key given in `dot-notation` with the indexes of the items moved by a merge
+func movedKey(moved map[string]string, key string) string {
+	if len(moved) == 0 {
+		return key
+	}
+	keys := splitKey(key)
+	for i := range keys {
+		if to, ok := moved[buildKey(keys[:i+1])]; ok {
+			keys = append(splitKey(to), keys[i+1:]...)
+		}
+	}
+	return buildKey(keys)
+}
+
+func indexOf(list []interface{}, match func(interface{}) bool) int {
+	for i, item := range list {
+		if match(item) {
+			return i
+		}
+	}
+	return -1
+}
+
+// typeName describe the type of the value in the conflicts
+func typeName(v interface{}) string {
+	if _, isMap := asMap(v); isMap {
+		return "a map"
+	}
+	if _, isList := v.([]interface{}); isList {
+		return "a list"
+	}
+	return fmt.Sprintf("a %T", v)
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeKeysWith(t *testing.T) {
+	base := func() ConfigMap {
+		return ConfigMap{
+			"plugins": []interface{}{"auth", "cache"},
+			"tags":    []interface{}{"a"},
+			"servers": []interface{}{
+				map[string]interface{}{"name": "api", "port": 80},
+				map[string]interface{}{"name": "web", "port": 81},
+			},
+		}
+	}
+	override := ConfigMap{
+		"plugins": []interface{}{"cache", "metrics"},
+		"tags":    []interface{}{"b"},
+		"servers": []interface{}{
+			map[string]interface{}{"name": "web", "port": 8081},
+			map[string]interface{}{"name": "admin", "port": 9000},
+		},
+	}
+
+	merged, err := MergeKeysWith(base(), copyMap(override), MergeOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, override["plugins"], merged["plugins"])
+
+	merged, err = MergeKeysWith(base(), copyMap(override), MergeOptions{
+		Lists: MergeRule{Strategy: MergeAppend},
+		Keys: map[string]MergeRule{
+			"plugins": {Strategy: MergeAppendUnique},
+			"servers": {Strategy: MergeByKey, ItemKey: "name"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"auth", "cache", "metrics"}, merged["plugins"])
+	assert.Equal(t, []interface{}{"a", "b"}, merged["tags"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "api", "port": 80},
+		map[string]interface{}{"name": "web", "port": 8081},
+		map[string]interface{}{"name": "admin", "port": 9000},
+	}, merged["servers"])
+}
+
+func TestMergeKeysWith_Conflicts(t *testing.T) {
+	m1 := ConfigMap{
+		"app":     ConfigMap{"host": "localhost"},
+		"plugins": []interface{}{"auth"},
+		"servers": []interface{}{map[string]interface{}{"name": "api"}},
+		"port":    80,
+	}
+	m2 := ConfigMap{
+		"app":     "localhost",
+		"plugins": "metrics",
+		"servers": []interface{}{"web"},
+		"port":    map[string]interface{}{"http": 80},
+	}
+	merged, err := MergeKeysWith(m1, m2, MergeOptions{
+		Keys: map[string]MergeRule{
+			"plugins": {Strategy: MergeAppend},
+			"servers": {Strategy: MergeByKey, ItemKey: "name"},
+		},
+		Strict: true,
+	})
+	assert.EqualError(t, err, strings.Join([]string{
+		"merge conflict at key app: can't merge a string into a map",
+		"merge conflict at key plugins: strategy append can't merge a string into a list",
+		"merge conflict at key port: can't merge a map into a int",
+		"merge conflict at key servers: item 0 has no key name",
+	}, "\n"))
+	var conflict *MergeConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Equal(t, "app", conflict.Key)
+	// the keys with conflicts keep the previous value
+	assert.Equal(t, ConfigMap{"host": "localhost"}, merged["app"])
+	assert.Equal(t, []interface{}{"auth"}, merged["plugins"])
+	assert.Equal(t, 80, merged["port"])
+
+	// without Strict the values replace the maps
+	merged, err = MergeKeysWith(ConfigMap{"app": ConfigMap{"host": "localhost"}}, ConfigMap{"app": nil}, MergeOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, ConfigMap{"app": nil}, ConfigMap(merged))
+	assert.Equal(t, map[string]interface{}{"app": "x"}, MergeKeys(ConfigMap{"app": ConfigMap{}}, ConfigMap{"app": "x"}))
+}
+
+func TestMergeKeysWith_Delete(t *testing.T) {
+	m2 := ConfigMap{
+		"host":  Delete,
+		"debug": nil,
+		"tls":   map[string]interface{}{"cert": Delete, "key": "k"},
+		"tags":  []interface{}{"a", Delete},
+	}
+	merged := MergeKeys(ConfigMap{"host": "localhost", "debug": true}, m2)
+	assert.Equal(t, map[string]interface{}{
+		"debug": nil,
+		"tls":   map[string]interface{}{"key": "k"},
+		"tags":  []interface{}{"a"},
+	}, merged)
+	// the map merged is not modified
+	assert.Equal(t, Delete, m2["tls"].(map[string]interface{})["cert"])
+
+	merged, err := MergeKeysWith(ConfigMap{"host": "localhost", "debug": true}, m2, MergeOptions{DeleteNulls: true})
+	assert.NoError(t, err)
+	assert.NotContains(t, merged, "debug")
+}
+
+func TestWithMergeOptions(t *testing.T) {
+	dir := t.TempDir()
+	base := writeTempFile(t, dir, "base.yaml", `
+plugins: [auth]
+servers:
+  - name: api
+    port: 80
+  - name: web
+    port: 81
+`)
+	local := writeTempFile(t, dir, "local.yaml", `
+plugins: [metrics]
+servers:
+  - name: web
+    port: 8081
+  - name: admin
+    port: 9000
+`)
+	config := New().WithMergeOptions(MergeOptions{
+		Keys: map[string]MergeRule{
+			"plugins": {Strategy: MergeAppend},
+			"servers": {Strategy: MergeByKey, ItemKey: "name"},
+		},
+	})
+	assert.NoError(t, config.LoadConfigs(base, local))
+	assert.Equal(t, []interface{}{"auth", "metrics"}, config.Get("plugins"))
+	assert.Equal(t, 8081, config.Get("servers.1.port"))
+	assert.Equal(t, "admin", config.Get("servers.2.name"))
+	// the origins follow the items merged
+	assert.Equal(t, Origin{Source: SourceFile, Path: base, Line: 2}, originOf(config, "plugins.0"))
+	assert.Equal(t, Origin{Source: SourceFile, Path: local, Line: 2}, originOf(config, "plugins.1"))
+	assert.Equal(t, Origin{Source: SourceFile, Path: base, Line: 5}, originOf(config, "servers.0.port"))
+	assert.Equal(t, Origin{Source: SourceFile, Path: local, Line: 5}, originOf(config, "servers.1.port"))
+	assert.Equal(t, Origin{Source: SourceFile, Path: local, Line: 7}, originOf(config, "servers.2.port"))
+
+	bad := writeTempFile(t, dir, "bad.yaml", "plugins: metrics\n")
+	err := config.LoadConfigs(bad)
+	var conflict *MergeConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Contains(t, err.Error(), "fail to merge configs from file "+bad)
+	// the previous configurations are kept
+	assert.Equal(t, []interface{}{"auth", "metrics"}, config.Get("plugins"))
+}
+
+func originOf(config *Config, key string) Origin {
+	origin, _ := config.Origin(key)
+	return origin
+}
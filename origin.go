@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Origin return where the value of the key given comes from,
+// key can be passed in `dot-notation` and should be a final value, not a map
+func (c *Config) Origin(key string) (Origin, bool) {
+	c.syncFlags()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	origin, ok := c.origins[normalizeKey(key)]
+	return origin, ok
+}
+
+// Explain return all the keys with the source of their values,
+// one key per line sorted by key
+func (c *Config) Explain() string {
+	c.syncFlags()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make(map[string]interface{}, len(c.origins))
+	for key := range c.origins {
+		keys[key] = nil
+	}
+	var b strings.Builder
+	for _, key := range sortedKeys(keys) {
+		fmt.Fprintf(&b, "%s: %s\n", key, c.origins[key])
+	}
+	return b.String()
+}
+
+func (o Origin) String() string {
+	s := o.Source
+	if o.Path != "" {
+		s += " " + o.Path
+	}
+	if o.Line > 0 {
+		s += fmt.Sprintf(":%d", o.Line)
+	}
+	if o.Env != "" {
+		s += " with env " + o.Env
+	}
+	return s
+}
+
+// addOrigins set the origin of all the keys in the map given
+func addOrigins(origins map[string]Origin, m ConfigMap, origin func(key string) Origin) {
+	for key := range Flatten(m) {
+		origins[key] = origin(key)
+	}
+}
+
+// addMergedOrigins is addOrigins for a map merged moving the items of
+// its lists, the origins are added with the key the items were moved to
+func addMergedOrigins(origins map[string]Origin, m ConfigMap, moved map[string]string, origin func(key string) Origin) {
+	for key := range Flatten(m) {
+		origins[movedKey(moved, key)] = origin(key)
+	}
+}
+
+// yamlLines return the line where each key is defined in the YAML content given,
+// the keys are in `dot-notation`
+func yamlLines(content []byte) map[string]int {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil || len(doc.Content) < 1 {
+		return nil
+	}
+	lines := make(map[string]int)
+	addYamlLines(doc.Content[0], nil, lines)
+	return lines
+}
+
+func addYamlLines(node *yaml.Node, keys []string, lines map[string]int) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.SequenceNode {
+		// the items of the lists are keys with their index
+		for i, item := range node.Content {
+			path := append(keys[:len(keys):len(keys)], strconv.Itoa(i))
+			lines[buildKey(path)] = item.Line
+			addYamlLines(item, path, lines)
+		}
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valNode := node.Content[i], node.Content[i+1]
+		// merge keys (<<) add the keys of the aliased maps at the same level
+		if keyNode.Value == "<<" {
+			addYamlLines(valNode, keys, lines)
+			continue
+		}
+		path := append(keys[:len(keys):len(keys)], keyNode.Value)
+		lines[buildKey(path)] = keyNode.Line
+		addYamlLines(valNode, path, lines)
+	}
+}
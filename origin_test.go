@@ -0,0 +1,85 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrigin(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	content := "app:\n  host: 127.0.0.1\n  port: 3001\n  url: http://${ORIGIN_TEST_HOST}\nlogger:\n  log_level: info\n"
+	assert.NoError(t, os.WriteFile(base, []byte(content), 0644))
+	override := filepath.Join(dir, "override.json")
+	assert.NoError(t, os.WriteFile(override, []byte(`{"app": {"port": 4000}}`), 0644))
+
+	t.Setenv("ORIGIN_TEST_HOST", "localhost")
+	t.Setenv("APP_LOGGER_LOG_LEVEL", "debug")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("app.debug", false, "")
+	assert.NoError(t, fs.Parse([]string{"--app.debug"}))
+
+	config := New().SetConfigImpl(&MockConfig{}).WithEnv().WithEnvPrefix("APP").BindFlagSet(fs)
+	config.SetDefault("app.timeout", "5s")
+	assert.NoError(t, config.LoadConfigs(base, override))
+	config.Set("app.name", "service")
+
+	tests := map[string]Origin{
+		"app.host":         {Source: SourceFile, Path: base, Line: 2},
+		"app.port":         {Source: SourceFile, Path: override},
+		"app.url":          {Source: SourceFile, Path: base, Line: 4, Env: "ORIGIN_TEST_HOST"},
+		"logger.log_level": {Source: SourceEnv, Path: "APP_LOGGER_LOG_LEVEL"},
+		"app.debug":        {Source: SourceFlag, Path: "app.debug"},
+		"app.timeout":      {Source: SourceDefault},
+		"app.name":         {Source: SourceSet},
+	}
+	for key, want := range tests {
+		got, ok := config.Origin(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, want, got, key)
+	}
+
+	_, ok := config.Origin("app")
+	assert.False(t, ok)
+	_, ok = config.Origin("missing")
+	assert.False(t, ok)
+
+	explain := config.Explain()
+	assert.Contains(t, explain, "app.host: file "+base+":2\n")
+	assert.Contains(t, explain, "app.url: file "+base+":4 with env ORIGIN_TEST_HOST\n")
+	assert.Contains(t, explain, "logger.log_level: env APP_LOGGER_LOG_LEVEL\n")
+	assert.Contains(t, explain, "app.debug: flag app.debug\n")
+	assert.Contains(t, explain, "app.name: set\n")
+	assert.True(t, strings.HasPrefix(explain, "app.debug: "))
+}
+
+func TestOriginReaderAndConfigMap(t *testing.T) {
+	config := New().SetConfigMap(ConfigMap{"a": 1, "b": 1})
+	assert.NoError(t, config.LoadReader(strings.NewReader("b: 2\n"), "yaml"))
+
+	origin, ok := config.Origin("a")
+	assert.True(t, ok)
+	assert.Equal(t, "config map", origin.String())
+
+	origin, ok = config.Origin("b")
+	assert.True(t, ok)
+	assert.Equal(t, Origin{Source: SourceReader, Path: "yaml"}, origin)
+}
+
+func TestYamlLines(t *testing.T) {
+	content := "defaults: &defaults\n  port: 1\nservice:\n  <<: *defaults\n  host: localhost\n"
+	lines := yamlLines([]byte(content))
+	assert.Equal(t, map[string]int{
+		"defaults":      1,
+		"defaults.port": 2,
+		"service":       3,
+		"service.port":  2,
+		"service.host":  5,
+	}, lines)
+	assert.Nil(t, yamlLines([]byte("a: [")))
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProfileEnv is the env variable with the profile loaded by LoadProfile
+// when no profile is given
+const ProfileEnv = "AYOTL_PROFILE"
+
+// WithProfile set the profile loaded by LoadProfile when no profile is given,
+// it has precedence over the ProfileEnv env variable
+func (c *Config) WithProfile(profile string) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.profile = profile
+	return c
+}
+
+// LoadProfile load the base config file and the overlay of the profile from the
+// directory given, like `config.yaml` and `config.production.yaml`, with any
+// extension with a decoder registered. the base file is loaded first and then
+// the profile file. the profile is the one given, or the one set by WithProfile,
+// or the value of the ProfileEnv env variable, without profile only the base file
+// is loaded. an error is returned if there is no base file, or if there are many
+// files with the same name and different extensions. the profile file is optional
+func (c *Config) LoadProfile(dir, base, profile string) error {
+	if base == "" {
+		return fmt.Errorf("configuration file should not be empty")
+	}
+	if profile == "" {
+		c.mu.RLock()
+		profile = c.profile
+		c.mu.RUnlock()
+	}
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	exts := decoderExts()
+	files := findConfigFiles(dir, base, exts)
+	if len(files) < 1 {
+		return fmt.Errorf("no configuration file %s found in %s", base, dir)
+	}
+	if len(files) > 1 {
+		return fmt.Errorf("many configuration files %s found in %s: %s", base, dir, strings.Join(files, ", "))
+	}
+	if profile != "" {
+		profileFiles := findConfigFiles(dir, base+"."+profile, exts)
+		if len(profileFiles) > 1 {
+			return fmt.Errorf("many configuration files %s.%s found in %s: %s", base, profile, dir, strings.Join(profileFiles, ", "))
+		}
+		files = append(files, profileFiles...)
+	}
+	sources := make([]configSource, 0, len(files))
+	for _, file := range files {
+		sources = append(sources, configSource{path: file})
+	}
+	return c.load(sources...)
+}
+
+// findConfigFiles return the files in the directory given named
+// as the name given with the extensions given
+func findConfigFiles(dir, name string, exts []string) []string {
+	files := make([]string, 0, 1)
+	for _, ext := range exts {
+		if file := filepath.Join(dir, name+"."+ext); isFile(file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	writeTempFile(t, dir, "config.yaml", "app:\n  host: localhost\n  port: 3001\n")
+	writeTempFile(t, dir, "config.production.yaml", "app:\n  host: example.com\n")
+	writeTempFile(t, dir, "config.staging.toml", "[app]\nhost = \"staging.example.com\"\n")
+
+	config := New()
+	assert.NoError(t, config.LoadProfile(dir, "config", "production"))
+	assert.Equal(t, "example.com", config.Get("app.host"))
+	assert.Equal(t, 3001, config.Get("app.port"))
+
+	t.Setenv(ProfileEnv, "staging")
+	config = New()
+	assert.NoError(t, config.LoadProfile(dir, "config", ""))
+	assert.Equal(t, "staging.example.com", config.Get("app.host"))
+
+	config = New().WithProfile("production")
+	assert.NoError(t, config.LoadProfile(dir, "config", ""))
+	assert.Equal(t, "example.com", config.Get("app.host"))
+
+	// the profile files are optional
+	config = New()
+	assert.NoError(t, config.LoadProfile(dir, "config", "development"))
+	assert.Equal(t, "localhost", config.Get("app.host"))
+
+	assert.EqualError(t, New().LoadProfile(dir, "app", "production"), "no configuration file app found in "+dir)
+
+	// the files with the same name and different extensions are ambiguous
+	writeTempFile(t, dir, "config.staging.json", `{"app": {"host": "staging.local"}}`)
+	err := New().LoadProfile(dir, "config", "staging")
+	assert.EqualError(t, err, "many configuration files config.staging found in "+dir+": "+
+		filepath.Join(dir, "config.staging.json")+", "+filepath.Join(dir, "config.staging.toml"))
+	writeTempFile(t, dir, "config.json", `{"app": {"name": "service"}}`)
+	err = New().LoadProfile(dir, "config", "")
+	assert.EqualError(t, err, "many configuration files config found in "+dir+": "+
+		filepath.Join(dir, "config.json")+", "+filepath.Join(dir, "config.yaml"))
+}
@@ -0,0 +1,546 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion is the JSON Schema draft used by GenerateSchema
+const SchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern match the durations accepted by time.ParseDuration
+const durationPattern = `^[-+]?(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|ms|s|m|h))+)$`
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema is a JSON Schema document, only the keywords listed are supported
+// by Validate, the schemas with any other keyword fail to decode
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 SchemaTypes        `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+	// boolean is set for the boolean schemas, true accept any value and false none
+	boolean *bool
+}
+
+// SchemaTypes are the JSON types allowed by a Schema,
+// encoded as a string when there is only one
+type SchemaTypes []string
+
+func (t SchemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *SchemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaTypes{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// schemaFields is Schema without its methods, to encode it with the json package
+type schemaFields Schema
+
+func (s Schema) MarshalJSON() ([]byte, error) {
+	if s.boolean != nil {
+		return json.Marshal(*s.boolean)
+	}
+	return json.Marshal(schemaFields(s))
+}
+
+// annotationKeywords are the keywords accepted but not used by Validate
+var annotationKeywords = []string{"$id", "$comment", "examples", "deprecated", "readOnly", "writeOnly"}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var boolean bool
+	if err := json.Unmarshal(data, &boolean); err == nil {
+		*s = Schema{boolean: &boolean}
+		return nil
+	}
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return err
+	}
+	unsupported := make([]string, 0)
+	for keyword := range keywords {
+		if !isSchemaKeyword(keyword) {
+			unsupported = append(unsupported, keyword)
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return fmt.Errorf("unsupported schema keywords %s", strings.Join(unsupported, ", "))
+	}
+	return json.Unmarshal(data, (*schemaFields)(s))
+}
+
+// isSchemaKeyword return true if the keyword is a field of Schema or an annotation
+func isSchemaKeyword(keyword string) bool {
+	typ := reflect.TypeOf(schemaFields{})
+	for i := 0; i < typ.NumField(); i++ {
+		if name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ","); name == keyword {
+			return true
+		}
+	}
+	for _, annotation := range annotationKeywords {
+		if annotation == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSchema is a function to read a JSON Schema from a json or yaml file
+func LoadSchema(file string) (*Schema, error) {
+	content, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	if ext := getFileExt(file); ext == "yaml" || ext == "yml" {
+		var doc interface{}
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return nil, fmt.Errorf("fail to load schema from %s: %w", file, err)
+		}
+		if content, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("fail to load schema from %s: %w", file, err)
+		}
+	}
+	schema := &Schema{}
+	if err := json.Unmarshal(content, schema); err != nil {
+		return nil, fmt.Errorf("fail to load schema from %s: %w", file, err)
+	}
+	return schema, nil
+}
+
+// ValidateSchema check the current configurations with the schema given,
+// useful to reject invalid configurations before Unmarshal
+func (c *Config) ValidateSchema(schema *Schema) error {
+	c.syncFlags()
+	c.mu.RLock()
+	cm := c.ConfigMap
+	c.mu.RUnlock()
+	return schema.Validate(cm)
+}
+
+// Validate check the ConfigMap given with the schema, supporting the keywords
+// type, properties, required, additionalProperties, items, enum, format (uri,
+// hostname and date-time), pattern, minimum, maximum and the length keywords.
+// the types are matched weakly, as Unmarshal decode the values, so the string
+// "3001" is an integer. all the errors are returned in a *ValidationError
+// with the keys in `dot-notation`
+func (s *Schema) Validate(cm ConfigMap) error {
+	v := &validation{}
+	v.validateSchema(s, map[string]interface{}(cm), "")
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+func (v *validation) validateSchema(s *Schema, val interface{}, key string) {
+	if s == nil {
+		return
+	}
+	if s.boolean != nil {
+		if !*s.boolean {
+			v.add(key, "false", errors.New("is not allowed"))
+		}
+		return
+	}
+	if len(s.Type) > 0 {
+		converted, ok := matchTypes(s.Type, val)
+		if !ok {
+			v.add(key, "type", fmt.Errorf("should be %s, got %s", strings.Join(s.Type, " or "), jsonType(val)))
+			return
+		}
+		val = converted
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, val) {
+		v.add(key, "enum", fmt.Errorf("should be one of %v", s.Enum))
+	}
+	if n, ok := toFloat(val); ok {
+		if s.Minimum != nil && n < *s.Minimum {
+			v.add(key, "minimum", fmt.Errorf("value should be at least %v", *s.Minimum))
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			v.add(key, "maximum", fmt.Errorf("value should be at most %v", *s.Maximum))
+		}
+	}
+	switch value := val.(type) {
+	case string:
+		v.validateString(s, value, key)
+	case []interface{}:
+		v.validateLength(key, "Items", len(value), s.MinItems, s.MaxItems)
+		for i, item := range value {
+			v.validateSchema(s.Items, item, joinKey(key, strconv.Itoa(i)))
+		}
+	default:
+		if m, isMap := asMap(val); isMap {
+			v.validateObject(s, m, key)
+		}
+	}
+}
+
+func (v *validation) validateObject(s *Schema, m map[string]interface{}, key string) {
+	v.validateLength(key, "Properties", len(m), s.MinProperties, s.MaxProperties)
+	for _, name := range s.Required {
+		if _, ok := m[name]; !ok {
+			v.add(joinKey(key, name), "required", errors.New("is required"))
+		}
+	}
+	for _, name := range sortedKeys(m) {
+		if prop, ok := s.Properties[name]; ok {
+			v.validateSchema(prop, m[name], joinKey(key, name))
+			continue
+		}
+		v.validateSchema(s.AdditionalProperties, m[name], joinKey(key, name))
+	}
+}
+
+func (v *validation) validateString(s *Schema, value, key string) {
+	v.validateLength(key, "Length", len([]rune(value)), s.MinLength, s.MaxLength)
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			v.add(key, "pattern", fmt.Errorf("invalid pattern %q: %w", s.Pattern, err))
+		} else if !re.MatchString(value) {
+			v.add(key, "pattern", fmt.Errorf("%q should match %s", value, s.Pattern))
+		}
+	}
+	switch s.Format {
+	case "uri":
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" {
+			v.add(key, "format", fmt.Errorf("%q is not a valid uri", value))
+		}
+	case "hostname":
+		if !isHostname(value) {
+			v.add(key, "format", fmt.Errorf("%q is not a valid hostname", value))
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			v.add(key, "format", fmt.Errorf("%q is not a valid date-time", value))
+		}
+	}
+}
+
+// validateLength check the length of strings, lists and maps with the min and max keywords
+func (v *validation) validateLength(key, what string, length int, min, max *int) {
+	if min != nil && length < *min {
+		v.add(key, "min"+what, fmt.Errorf("length should be at least %d", *min))
+	}
+	if max != nil && length > *max {
+		v.add(key, "max"+what, fmt.Errorf("length should be at most %d", *max))
+	}
+}
+
+// jsonType return the JSON type of the value
+func jsonType(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string, time.Time:
+		return "string"
+	case time.Duration:
+		return "integer"
+	case []interface{}:
+		return "array"
+	}
+	if _, isMap := asMap(val); isMap {
+		return "object"
+	}
+	if n, ok := toFloat(val); ok {
+		if n == float64(int64(n)) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", val)
+}
+
+// matchTypes return the value as one of the types given, the values of other
+// types are converted as Unmarshal do with WeaklyTypedInput
+func matchTypes(types []string, val interface{}) (interface{}, bool) {
+	valType := jsonType(val)
+	for _, t := range types {
+		switch {
+		case t == valType,
+			t == "number" && valType == "integer",
+			// durations can be given as strings or as nanoseconds
+			t == "string" && valType == "integer" && reflect.TypeOf(val) == durationType:
+			return val, true
+		}
+	}
+	for _, t := range types {
+		if converted, ok := weakConvert(t, valType, val); ok {
+			return converted, true
+		}
+	}
+	return val, false
+}
+
+// weakConvert convert the strings to numbers and booleans, and
+// the numbers and booleans to strings
+func weakConvert(t, valType string, val interface{}) (interface{}, bool) {
+	str, isString := val.(string)
+	switch {
+	case t == "integer" && isString:
+		n, err := strconv.ParseInt(str, 0, 64)
+		return n, err == nil
+	case t == "number" && isString:
+		n, err := strconv.ParseFloat(str, 64)
+		return n, err == nil
+	case t == "boolean" && isString:
+		b, err := strconv.ParseBool(str)
+		return b, err == nil
+	case t == "string" && (valType == "integer" || valType == "number" || valType == "boolean"):
+		return fmt.Sprint(val), true
+	}
+	return val, false
+}
+
+func inEnum(enum []interface{}, val interface{}) bool {
+	n, isNumber := toFloat(val)
+	for _, option := range enum {
+		if optionNumber, ok := toFloat(option); ok && isNumber {
+			if n == optionNumber {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(option, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// toFloat return the value of the numbers as float64
+func toFloat(val interface{}) (float64, bool) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// GenerateSchema is a function to generate the JSON Schema of the struct given,
+// the properties are named as the mapstructure keys used by Unmarshal and the
+// `validate` and `default` struct tags are added as constraints and defaults,
+// so the schema can be used by editors to complete and check the config files
+func GenerateSchema(s any) (*Schema, error) {
+	schema, err := typeSchema(reflect.TypeOf(s), "", make(map[reflect.Type]bool))
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = SchemaVersion
+	return schema, nil
+}
+
+func typeSchema(typ reflect.Type, key string, visiting map[reflect.Type]bool) (*Schema, error) {
+	typ = derefType(typ)
+	if typ == nil {
+		return &Schema{}, nil
+	}
+	switch {
+	case typ == durationType:
+		return &Schema{Type: SchemaTypes{"string", "integer"}, Pattern: durationPattern}, nil
+	case typ == timeType:
+		return &Schema{Type: SchemaTypes{"string"}, Format: "date-time"}, nil
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: SchemaTypes{"boolean"}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: SchemaTypes{"integer"}}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		min := float64(0)
+		return &Schema{Type: SchemaTypes{"integer"}, Minimum: &min}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: SchemaTypes{"number"}}, nil
+	case reflect.String:
+		return &Schema{Type: SchemaTypes{"string"}}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(typ.Elem(), joinKey(key, "0"), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: SchemaTypes{"array"}, Items: items}, nil
+	case reflect.Map:
+		values, err := typeSchema(typ.Elem(), joinKey(key, "*"), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: SchemaTypes{"object"}, AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if visiting[typ] {
+			// recursive types accept any object from the second level
+			return &Schema{Type: SchemaTypes{"object"}}, nil
+		}
+		visiting[typ] = true
+		defer delete(visiting, typ)
+		schema := &Schema{Type: SchemaTypes{"object"}, Properties: make(map[string]*Schema)}
+		if err := structSchema(schema, typ, key, visiting); err != nil {
+			return nil, err
+		}
+		return schema, nil
+	}
+	// interfaces and any other kind accept any value
+	return &Schema{}, nil
+}
+
+// structSchema add the fields of the struct to the properties of the schema
+func structSchema(schema *Schema, typ reflect.Type, key string, visiting map[reflect.Type]bool) error {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, squash := fieldKey(field)
+		if name == "-" {
+			continue
+		}
+		if squash {
+			if embedded := derefType(field.Type); embedded.Kind() == reflect.Struct {
+				if err := structSchema(schema, embedded, key, visiting); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		fieldKey := joinKey(key, name)
+		prop, err := typeSchema(field.Type, fieldKey, visiting)
+		if err != nil {
+			return err
+		}
+		if tag, ok := field.Tag.Lookup("default"); ok {
+			value, err := schemaValue(tag, derefType(field.Type))
+			if err != nil {
+				return fmt.Errorf("invalid default for key %s: %w", fieldKey, err)
+			}
+			prop.Default = value
+		}
+		if tag, ok := field.Tag.Lookup("validate"); ok {
+			required, err := addRules(prop, derefType(field.Type), tag)
+			if err != nil {
+				return fmt.Errorf("invalid validate tag for key %s: %w", fieldKey, err)
+			}
+			if required {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		schema.Properties[name] = prop
+	}
+	return nil
+}
+
+// addRules add the constraints of the `validate` tag to the schema,
+// returning true if the field is required
+func addRules(schema *Schema, typ reflect.Type, tag string) (bool, error) {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "required":
+			required = true
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return false, fmt.Errorf("invalid %s value %q", name, arg)
+			}
+			addLimit(schema, typ, name, limit)
+		case "oneof":
+			for _, option := range strings.Fields(arg) {
+				value, err := schemaValue(option, typ)
+				if err != nil {
+					return false, err
+				}
+				schema.Enum = append(schema.Enum, value)
+			}
+		case "url":
+			schema.Format = "uri"
+		case "hostname":
+			schema.Format = "hostname"
+		}
+	}
+	return required, nil
+}
+
+// schemaValue convert the value of a tag to the type of the field,
+// durations are kept as strings
+func schemaValue(value string, typ reflect.Type) (interface{}, error) {
+	if typ == durationType {
+		if _, err := time.ParseDuration(value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	return convertDefault(value, typ)
+}
+
+// addLimit add the min and max rules as the keyword for the type of the field
+func addLimit(schema *Schema, typ reflect.Type, rule string, limit float64) {
+	length := int(limit)
+	switch typ.Kind() {
+	case reflect.String:
+		setLimit(rule, &schema.MinLength, &schema.MaxLength, length)
+	case reflect.Slice, reflect.Array:
+		setLimit(rule, &schema.MinItems, &schema.MaxItems, length)
+	case reflect.Map:
+		setLimit(rule, &schema.MinProperties, &schema.MaxProperties, length)
+	default:
+		if rule == "min" {
+			schema.Minimum = &limit
+		} else {
+			schema.Maximum = &limit
+		}
+	}
+}
+
+func setLimit(rule string, min, max **int, limit int) {
+	if rule == "min" {
+		*min = &limit
+		return
+	}
+	*max = &limit
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaLogin struct {
+	Host    string        `mapstructure:"host" validate:"required,hostname" default:"127.0.0.1"`
+	Port    int           `mapstructure:"port" validate:"min=1,max=65535"`
+	Timeout time.Duration `mapstructure:"timeout" default:"5s"`
+}
+
+type schemaConfig struct {
+	Stage    string                 `mapstructure:"stage" validate:"required,oneof=dev prod"`
+	Proxy    string                 `mapstructure:"proxy" validate:"omitempty,url"`
+	Tags     []string               `mapstructure:"tags" validate:"max=3"`
+	Login    *schemaLogin           `mapstructure:"login"`
+	Services map[string]schemaLogin `mapstructure:"services"`
+	Ignored  string                 `mapstructure:"-"`
+}
+
+func TestGenerateSchema(t *testing.T) {
+	schema, err := GenerateSchema(&schemaConfig{})
+	assert.NoError(t, err)
+	content, err := json.Marshal(schema)
+	assert.NoError(t, err)
+
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(content, &doc))
+	assert.Equal(t, SchemaVersion, doc["$schema"])
+	assert.Equal(t, "object", doc["type"])
+	assert.Equal(t, []interface{}{"stage"}, doc["required"])
+
+	props := doc["properties"].(map[string]interface{})
+	assert.NotContains(t, props, "Ignored")
+	assert.Equal(t, map[string]interface{}{"type": "string", "enum": []interface{}{"dev", "prod"}}, props["stage"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "uri"}, props["proxy"])
+	assert.Equal(t, map[string]interface{}{
+		"type":     "array",
+		"items":    map[string]interface{}{"type": "string"},
+		"maxItems": float64(3),
+	}, props["tags"])
+
+	login := props["login"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"host"}, login["required"])
+	loginProps := login["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "hostname", "default": "127.0.0.1"}, loginProps["host"])
+	assert.Equal(t, map[string]interface{}{"type": "integer", "minimum": float64(1), "maximum": float64(65535)}, loginProps["port"])
+	assert.Equal(t, []interface{}{"string", "integer"}, loginProps["timeout"].(map[string]interface{})["type"])
+	assert.Equal(t, "5s", loginProps["timeout"].(map[string]interface{})["default"])
+
+	services := props["services"].(map[string]interface{})
+	assert.Equal(t, login, services["additionalProperties"])
+
+	// the generated schema validate the configurations
+	config := New()
+	config.SetConfigMap(ConfigMap{
+		"stage":    "prod",
+		"login":    ConfigMap{"host": "localhost", "port": 8080, "timeout": "10s"},
+		"services": ConfigMap{"auth": ConfigMap{"host": "auth.local"}},
+	})
+	assert.NoError(t, config.ValidateSchema(schema))
+}
+
+func TestSchemaValidate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "schema.yaml")
+	content := `
+type: object
+required: [stage, login]
+additionalProperties: false
+properties:
+  stage:
+    type: string
+    enum: [dev, prod]
+  login:
+    type: object
+    properties:
+      host:
+        type: string
+        format: hostname
+      port:
+        type: integer
+        minimum: 1
+  servers:
+    type: array
+    maxItems: 2
+    items:
+      type: object
+      required: [host]
+`
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	schema, err := LoadSchema(file)
+	assert.NoError(t, err)
+
+	config := New()
+	config.SetConfigMap(ConfigMap{
+		"stage":   "test",
+		"login":   ConfigMap{"host": "-bad-", "port": 0},
+		"servers": []interface{}{ConfigMap{"host": "a"}, ConfigMap{"port": 1}},
+		"extra":   true,
+	})
+	err = config.ValidateSchema(schema)
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "invalid configurations:\n"+
+		"extra: is not allowed\n"+
+		"login.host: \"-bad-\" is not a valid hostname\n"+
+		"login.port: value should be at least 1\n"+
+		"servers.1.host: is required\n"+
+		"stage: should be one of [dev prod]", err.Error())
+
+	assert.NoError(t, schema.Validate(ConfigMap{"stage": "dev", "login": ConfigMap{"port": 3000}}))
+	// the types are matched as Unmarshal decode the values
+	assert.NoError(t, schema.Validate(ConfigMap{"stage": "dev", "login": ConfigMap{"port": "3000", "host": 3000}}))
+	err = schema.Validate(ConfigMap{"stage": "dev", "login": ConfigMap{"port": "http"}})
+	assert.EqualError(t, err, "invalid configurations:\nlogin.port: should be integer, got string")
+	err = schema.Validate(ConfigMap{"stage": "dev", "login": ConfigMap{"port": "0"}})
+	assert.EqualError(t, err, "invalid configurations:\nlogin.port: value should be at least 1")
+
+	// json files decode the numbers as float64
+	jsonFile := filepath.Join(dir, "config.json")
+	assert.NoError(t, os.WriteFile(jsonFile, []byte(`{"stage": "prod", "login": {"port": 3000}}`), 0644))
+	jsonConfig := New()
+	assert.NoError(t, jsonConfig.LoadConfigs(jsonFile))
+	assert.NoError(t, jsonConfig.ValidateSchema(schema))
+
+	// the env overrides are strings
+	t.Setenv("APP_LOGIN_PORT", "9090")
+	envConfig := New().WithEnvPrefix("APP")
+	envConfig.SetConfigMap(ConfigMap{"stage": "prod", "login": ConfigMap{"host": "localhost", "port": 3000}})
+	assert.NoError(t, envConfig.ValidateSchema(schema))
+	generated, err := GenerateSchema(&schemaConfig{})
+	assert.NoError(t, err)
+	assert.NoError(t, envConfig.ValidateSchema(generated))
+	assert.Equal(t, "9090", envConfig.Get("login.port"))
+}
+
+func TestLoadSchemaUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "schema.json")
+	content := `{"$id": "config", "type": "object", "properties": {"port": {"$ref": "#/$defs/port", "const": 1}}}`
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	_, err := LoadSchema(file)
+	assert.EqualError(t, err, "fail to load schema from "+file+": unsupported schema keywords $ref, const")
+}
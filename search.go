@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrConfigNotFound is returned by LoadConfigs when a configuration file is not found
+var ErrConfigNotFound = errors.New("configuration file not found")
+
+// AddConfigPath add paths to search the relative files given to LoadConfigs, the
+// paths are searched in the order they are added and the env variables in them
+// are expanded, like `$HOME/.app`
+func (c *Config) AddConfigPath(paths ...string) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, path := range paths {
+		if path = os.ExpandEnv(path); path != "" {
+			c.configPaths = append(c.configPaths, path)
+		}
+	}
+	return c
+}
+
+// SetConfigName set the name of the file, without extension, loaded by LoadConfigs
+// when no files are given. it is searched in the config paths, or in the current
+// directory, with any extension with a decoder registered, the first file found is loaded
+func (c *Config) SetConfigName(name string) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.configName = name
+	return c
+}
+
+// searchFile return the path of the file given and true if it exists,
+// the relative files are searched in the paths given
+func searchFile(paths []string, file string) (string, bool) {
+	if len(paths) < 1 || filepath.IsAbs(file) {
+		return file, isFile(file)
+	}
+	for _, path := range paths {
+		if candidate := filepath.Join(path, file); isFile(candidate) {
+			return candidate, true
+		}
+	}
+	return filepath.Join(paths[0], file), false
+}
+
+// findConfigFile return the first file named as the name given found in
+// the paths given, or in the current directory if there are no paths
+func findConfigFile(paths []string, name string) (string, bool) {
+	if len(paths) < 1 {
+		paths = []string{"."}
+	}
+	exts := decoderExts()
+	for _, path := range paths {
+		if files := findConfigFiles(path, name, exts); len(files) > 0 {
+			return files[0], true
+		}
+	}
+	return "", false
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
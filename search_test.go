@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddConfigPath(t *testing.T) {
+	etc, home := t.TempDir(), t.TempDir()
+	t.Setenv("APP_HOME", home)
+	writeTempFile(t, etc, "config.yaml", "app:\n  host: localhost\n  port: 3001\n")
+	writeTempFile(t, home, "config.json", `{"app": {"host": "example.com"}}`)
+	writeTempFile(t, home, "local.yaml", "app:\n  port: 3002\n")
+
+	config := New().AddConfigPath("$APP_HOME", etc).SetConfigName("config")
+	assert.NoError(t, config.LoadConfigs())
+	// the first path with the file wins
+	assert.Equal(t, "example.com", config.Get("app.host"))
+	assert.Nil(t, config.Get("app.port"))
+
+	config = New().AddConfigPath(etc, "$APP_HOME")
+	assert.NoError(t, config.LoadConfigs("config.yaml", "local.yaml"))
+	assert.Equal(t, "localhost", config.Get("app.host"))
+	assert.Equal(t, 3002, config.Get("app.port"))
+	origin, _ := config.Origin("app.port")
+	assert.Equal(t, filepath.Join(home, "local.yaml"), origin.Path)
+
+	err := New().AddConfigPath(etc).SetConfigName("app").LoadConfigs()
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+	err = New().AddConfigPath(etc).LoadConfigs("local.json")
+	assert.True(t, errors.Is(err, ErrConfigNotFound))
+	assert.EqualError(t, err, "fail to load configs from file local.json: configuration file not found")
+}
+
+func TestOptional(t *testing.T) {
+	dir := t.TempDir()
+	base := writeTempFile(t, dir, "config.yaml", "app:\n  port: 3001\n")
+	local := filepath.Join(dir, "local.yaml")
+
+	config := New()
+	assert.NoError(t, config.LoadConfigs(base))
+	assert.NoError(t, config.LoadOptional(local))
+	assert.Equal(t, 3001, config.Get("app.port"))
+
+	// the optional files are loaded once they are created
+	changed := make(chan ConfigMap, 1)
+	config.OnChange(func(_, new ConfigMap) { changed <- new })
+	stop := config.WatchConfig(10 * time.Millisecond)
+	defer stop()
+	assert.NoError(t, os.WriteFile(local, []byte("app:\n  port: 3002\n"), 0644))
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the optional file created was not loaded")
+	}
+	assert.Equal(t, 3002, config.Get("app.port"))
+
+	// the optional files are searched in the config paths
+	config = New().AddConfigPath(t.TempDir(), dir)
+	assert.NoError(t, config.LoadOptional("config.yaml", "missing.yaml"))
+	assert.Equal(t, 3001, config.Get("app.port"))
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// configSource is a place where configurations are loaded from,
+// sources are remembered so they can be loaded again on reload
+type configSource struct {
+	// path of the file, inside fsys if is not nil
+	path string
+	fsys fs.FS
+	// format and content of the configs read from a io.Reader
+	format  string
+	content []byte
+	// optional files are loaded empty when they don't exist
+	optional bool
+}
+
+// equal return true if both sources are the same, the readers with the same content too
+func (s configSource) equal(other configSource) bool {
+	return reflect.DeepEqual(s, other)
+}
+
+// layer is the configs loaded from a source
+type layer struct {
+	source configSource
+	data   ConfigMap
+	// lines has the line where each key is defined, for YAML sources
+	lines map[string]int
+	// includes has the files included by the source, in the same filesystem
+	includes []string
+	// includeOrigins has the origins of the keys set by the files included
+	includeOrigins map[string]Origin
+}
+
+// watched return the source and the files it includes, to detect changes
+func (l layer) watched() []configSource {
+	sources := make([]configSource, 0, len(l.includes)+1)
+	sources = append(sources, l.source)
+	for _, file := range l.includes {
+		sources = append(sources, configSource{path: file, fsys: l.source.fsys})
+	}
+	return sources
+}
+
+// override is a value set for a key, the overrides are applied in order
+// over the merged layers, so the keys can have indexes of the lists
+type override struct {
+	key   string
+	value interface{}
+}
+
+// setOverride add the value of the key to the overrides given, replacing the previous
+// value of the same key. it is replaced in place, as the next overrides can set the items
+// appended by it, unless the next overrides set the key or its nested keys too
+func setOverride(overrides []override, key string, value interface{}) []override {
+	out := make([]override, 0, len(overrides)+1)
+	for i, o := range overrides {
+		if o.key != key {
+			out = append(out, o)
+			continue
+		}
+		if !overlapKeys(overrides[i+1:], key) {
+			out = append(out, override{key: key, value: value})
+			return append(out, overrides[i+1:]...)
+		}
+	}
+	return append(out, override{key: key, value: value})
+}
+
+// overlapKeys return true if any of the overrides set the key given, its parents or its nested keys
+func overlapKeys(overrides []override, key string) bool {
+	keys := splitKey(key)
+	for _, o := range overrides {
+		other := splitKey(o.key)
+		n := len(keys)
+		if len(other) < n {
+			n = len(other)
+		}
+		if reflect.DeepEqual(keys[:n], other[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOverrides set the overrides given in the map, adding their origins,
+// the keys with the Delete value are removed
+func applyOverrides(cm ConfigMap, overrides []override, origins map[string]Origin, origin func(key string) Origin) ConfigMap {
+	for _, o := range overrides {
+		keys := splitKey(o.key)
+		if isDelete(o.value) {
+			// the origins of the keys removed are dropped by build
+			cm = deleteValue(cm, keys)
+			continue
+		}
+		resolved := resolveKey(cm, keys)
+		SetValue(cm, keys, copyValue(o.value))
+		addOrigins(origins, SetValue(make(ConfigMap), resolved, o.value), func(string) Origin { return origin(o.key) })
+	}
+	return cm
+}
+
+// read load and decode the configs from the source
+func (s configSource) read() (layer, error) {
+	content, ext, err := s.readContent()
+	if err != nil && s.optional && errors.Is(err, fs.ErrNotExist) {
+		return layer{source: s, data: make(ConfigMap)}, nil
+	}
+	if err != nil {
+		return layer{}, err
+	}
+	data, err := decode(content, ext)
+	if err != nil {
+		return layer{}, err
+	}
+	data, in, err := resolveIncludes(data, s.fsys, s.path)
+	if err != nil {
+		return layer{}, err
+	}
+	l := layer{source: s, data: data, includes: in.files, includeOrigins: in.origins}
+	if ext == "yaml" || ext == "yml" {
+		l.lines = yamlLines(content)
+	}
+	return l, nil
+}
+
+// readContent return the content of the source and the extension used to decode it
+func (s configSource) readContent() ([]byte, string, error) {
+	switch {
+	case s.content != nil:
+		return s.content, normalizeExt(s.format), nil
+	case s.fsys != nil:
+		content, err := fs.ReadFile(s.fsys, s.path)
+		return content, getFileExt(s.path), err
+	}
+	content, err := os.ReadFile(filepath.Clean(s.path))
+	return content, getFileExt(s.path), err
+}
+
+// origin return the Origin of the key given loaded from the source
+func (s configSource) origin(lines map[string]int, key string) Origin {
+	if s.content != nil {
+		return Origin{Source: SourceReader, Path: s.format}
+	}
+	return Origin{Source: SourceFile, Path: s.path, Line: lines[key]}
+}
+
+// stat return the current state of the source, used to detect changes,
+// the content read from a io.Reader never change
+func (s configSource) stat() fileState {
+	var (
+		content []byte
+		err     error
+	)
+	switch {
+	case s.content != nil:
+		content = s.content
+	case s.fsys != nil:
+		content, err = fs.ReadFile(s.fsys, s.path)
+	default:
+		content, err = os.ReadFile(s.path)
+	}
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, hash: sha256.Sum256(content)}
+}
+
+// String return the name of the source used in errors
+func (s configSource) String() string {
+	if s.content != nil {
+		return fmt.Sprintf("reader (%s)", s.format)
+	}
+	return "file " + s.path
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// indexPattern match the indexes of slices and maps in the mapstructure keys, like servers[0]
+var indexPattern = regexp.MustCompile(`\[([^\]]*)\]`)
+
+// StrictError is returned by UnmarshalStrict when the configurations and
+// the struct don't match, with the keys in `dot-notation`
+type StrictError struct {
+	// Unused are the keys of the configurations not used by the struct
+	Unused []string
+	// Unset are the fields of the struct without a key in the configurations
+	Unset []string
+}
+
+func (e *StrictError) Error() string {
+	var msgs []string
+	if len(e.Unused) > 0 {
+		msgs = append(msgs, "unused keys: "+strings.Join(e.Unused, ", "))
+	}
+	if len(e.Unset) > 0 {
+		msgs = append(msgs, "unset fields: "+strings.Join(e.Unset, ", "))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// UnmarshalStrict is Unmarshal returning a *StrictError when there are keys in the
+// configurations not used by the struct, like typos, or fields of the struct
+// without value. fields with a `default` tag are always set
+func (c *Config) UnmarshalStrict(s any) error {
+	return c.unmarshal("", s, true)
+}
+
+// newStrictError return the error for the keys not used and fields not set in the metadata,
+// or nil if there are none
+func newStrictError(metadata *mapstructure.Metadata) error {
+	if len(metadata.Unused) == 0 && len(metadata.Unset) == 0 {
+		return nil
+	}
+	return &StrictError{
+		Unused: metadataKeys(metadata.Unused),
+		Unset:  metadataKeys(metadata.Unset),
+	}
+}
+
+// metadataKeys convert the mapstructure keys to `dot-notation`, sorted
+func metadataKeys(keys []string) []string {
+	if len(keys) == 0 {
+		return nil
+	}
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		out[i] = indexPattern.ReplaceAllString(key, ".$1")
+	}
+	sort.Strings(out)
+	return out
+}
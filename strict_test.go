@@ -0,0 +1,57 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type strictServer struct {
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port" default:"80"`
+}
+
+type strictConfig struct {
+	Stage   string                  `mapstructure:"stage"`
+	Servers []strictServer          `mapstructure:"servers"`
+	Login   strictServer            `mapstructure:"login"`
+	Extra   map[string]strictServer `mapstructure:"extra"`
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	t.Run("matching configurations should not return errors", func(t *testing.T) {
+		config := New()
+		config.SetConfigMap(ConfigMap{
+			"stage":   "dev",
+			"servers": []interface{}{ConfigMap{"host": "a", "port": 1}},
+			"login":   ConfigMap{"host": "b"},
+			"extra":   ConfigMap{},
+		})
+		cfg := &strictConfig{}
+		assert.NoError(t, config.UnmarshalStrict(cfg))
+		assert.Equal(t, 80, cfg.Login.Port)
+	})
+
+	t.Run("unused keys and unset fields should be returned", func(t *testing.T) {
+		config := New()
+		config.SetConfigMap(ConfigMap{
+			"stage":   "dev",
+			"servces": ConfigMap{"login": true},
+			"servers": []interface{}{ConfigMap{"hots": "a"}},
+			"login":   ConfigMap{"host": "b"},
+			"extra":   ConfigMap{"auth": ConfigMap{"host": "c", "user": "d"}},
+		})
+		cfg := &strictConfig{}
+		err := config.UnmarshalStrict(cfg)
+		var strictErr *StrictError
+		assert.True(t, errors.As(err, &strictErr))
+		assert.Equal(t, []string{"extra.auth.user", "servces", "servers.0.hots"}, strictErr.Unused)
+		assert.Equal(t, []string{"servers.0.host"}, strictErr.Unset)
+		assert.EqualError(t, err, "unable to unmarshal configurations: "+
+			"unused keys: extra.auth.user, servces, servers.0.hots; unset fields: servers.0.host")
+
+		// the same configurations are accepted by Unmarshal
+		assert.NoError(t, config.Unmarshal(&strictConfig{}))
+	})
+}
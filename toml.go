@@ -0,0 +1,57 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// tomlDecode decode a TOML document into a ConfigMap, tables are decoded as
+// map[string]interface{} and arrays as []interface{}. the integers are converted
+// to int, as the YAML decoder does, floats are float64, offset date-times are
+// time.Time, local date-times and dates are time.Time in the local timezone
+// and local times are strings
+func tomlDecode(j []byte, d *ConfigMap) error {
+	var m map[string]interface{}
+	if err := toml.Unmarshal(j, &m); err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			row, _ := decodeErr.Position()
+			return fmt.Errorf("toml: line %d: %s", row, strings.TrimPrefix(decodeErr.Error(), "toml: "))
+		}
+		return err
+	}
+	for key, val := range m {
+		(*d)[key] = normalizeToml(val)
+	}
+	return nil
+}
+
+// normalizeToml convert the values decoded to the types returned by the other decoders
+func normalizeToml(val interface{}) interface{} {
+	switch v := val.(type) {
+	case int64:
+		if v >= math.MinInt && v <= math.MaxInt {
+			return int(v)
+		}
+	case toml.LocalDateTime:
+		return v.AsTime(time.Local)
+	case toml.LocalDate:
+		return v.AsTime(time.Local)
+	case toml.LocalTime:
+		return v.String()
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeToml(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeToml(item)
+		}
+	}
+	return val
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadFileTOML(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+# application configs
+stage = "development"
+
+[app]
+host = "127.0.0.1"
+port = 3001
+
+[services.login]
+host = "127.0.0.1"
+port = 3002
+enabled = true
+`
+	path := writeTempFile(t, dir, "config.toml", content)
+
+	m, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile(.toml) returned unexpected error: %v", err)
+	}
+	if v, _ := m["stage"].(string); v != "development" {
+		t.Fatalf("expected stage=development, got %#v", m["stage"])
+	}
+	if v := GetValue(m, []string{"services", "login", "port"}); v != 3002 {
+		t.Fatalf("expected services.login.port=3002, got %#v", v)
+	}
+
+	c := New()
+	if err := c.LoadConfigs(path); err != nil {
+		t.Fatalf("LoadConfigs(.toml) returned unexpected error: %v", err)
+	}
+	if v := c.Get("app.port"); v != 3001 {
+		t.Fatalf("expected app.port=3001, got %#v", v)
+	}
+
+	var out struct {
+		App struct {
+			Host string `mapstructure:"host"`
+			Port int    `mapstructure:"port"`
+		} `mapstructure:"app"`
+		Services struct {
+			Login struct {
+				Enabled bool `mapstructure:"enabled"`
+			} `mapstructure:"login"`
+		} `mapstructure:"services"`
+	}
+	if err := c.Unmarshal(&out); err != nil {
+		t.Fatalf("Unmarshal returned unexpected error: %v", err)
+	}
+	if out.App.Host != "127.0.0.1" || out.App.Port != 3001 || !out.Services.Login.Enabled {
+		t.Fatalf("unexpected unmarshal result: %#v", out)
+	}
+}
+
+func TestTomlDecodeValues(t *testing.T) {
+	content := `
+basic = "tab\tquote\" \u00e9"
+literal = 'C:\Users\path'
+multi = """
+first \
+  second"""
+multi_literal = '''
+raw \n'''
+hex = 0xff
+oct = 0o17
+bin = 0b101
+big = 1_000_000
+negative = -17
+float = 6.626e-34
+pi = 3.1415
+infinity = -inf
+odt = 1979-05-27T07:32:00Z
+odt_space = 1979-05-27 07:32:00-08:00
+local_time = 07:32:00
+arr = [ 1, 2, 3, ]
+nested = [
+  [ "a", "b" ], # comment
+  [ 1.5 ],
+]
+inline = { x = 1, y.z = "two" }
+site."example.com".port = 80
+`
+	m := make(ConfigMap)
+	if err := tomlDecode([]byte(content), &m); err != nil {
+		t.Fatalf("tomlDecode returned unexpected error: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"basic":         "tab\tquote\" \u00e9",
+		"literal":       `C:\Users\path`,
+		"multi":         "first second",
+		"multi_literal": `raw \n`,
+		"hex":           255,
+		"oct":           15,
+		"bin":           5,
+		"big":           1000000,
+		"negative":      -17,
+		"float":         6.626e-34,
+		"pi":            3.1415,
+		"infinity":      math.Inf(-1),
+		"local_time":    "07:32:00",
+		"odt":           time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC),
+		"arr":           []interface{}{1, 2, 3},
+		"nested":        []interface{}{[]interface{}{"a", "b"}, []interface{}{1.5}},
+		"inline":        map[string]interface{}{"x": 1, "y": map[string]interface{}{"z": "two"}},
+		"site":          map[string]interface{}{"example.com": map[string]interface{}{"port": 80}},
+	}
+	for key, val := range want {
+		if !reflect.DeepEqual(m[key], val) {
+			t.Fatalf("key %s = %#v, want %#v", key, m[key], val)
+		}
+	}
+	odt, ok := m["odt_space"].(time.Time)
+	if !ok || !odt.Equal(time.Date(1979, 5, 27, 15, 32, 0, 0, time.UTC)) {
+		t.Fatalf("key odt_space = %#v", m["odt_space"])
+	}
+}
+
+func TestTomlDecodeArrayOfTables(t *testing.T) {
+	content := `
+[[servers]]
+name = "alpha"
+ip = "10.0.0.1"
+
+[servers.meta]
+zone = "a"
+
+[[servers]]
+name = "beta"
+`
+	m := make(ConfigMap)
+	if err := tomlDecode([]byte(content), &m); err != nil {
+		t.Fatalf("tomlDecode returned unexpected error: %v", err)
+	}
+	want := []interface{}{
+		map[string]interface{}{"name": "alpha", "ip": "10.0.0.1", "meta": map[string]interface{}{"zone": "a"}},
+		map[string]interface{}{"name": "beta"},
+	}
+	if !reflect.DeepEqual(m["servers"], want) {
+		t.Fatalf("servers = %#v, want %#v", m["servers"], want)
+	}
+}
+
+func TestTomlDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"reopened inline table": "a = {b = 1}\n[a]\nc = 2",
+		"reopened dotted table": "a.b = 1\n[a]\nc = 2",
+		"leading zeros":         "n = 012",
+		"duplicated key":        "a = 1\na = 2",
+		"duplicated table":      "[a]\nx = 1\n[a]\ny = 2",
+		"missing value":         "a = ",
+		"unterminated":          `a = "abc`,
+		"invalid number":        "a = 1__0",
+		"missing equal":         "a 1",
+		"extra tokens":          "a = 1 2",
+	}
+	for name, content := range tests {
+		m := make(ConfigMap)
+		err := tomlDecode([]byte(content), &m)
+		if err == nil {
+			t.Fatalf("%s: expected error, got nil", name)
+		}
+		if !strings.HasPrefix(err.Error(), "toml: ") {
+			t.Fatalf("%s: expected toml error, got: %v", name, err)
+		}
+	}
+}
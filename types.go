@@ -1,13 +1,111 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+)
+
 type ConfigMap map[string]interface{}
 
+// DecoderFunc decode the content of a file into a ConfigMap
+type DecoderFunc func(content []byte) (ConfigMap, error)
+
+// ValueType is the type the value of a substituted env placeholder is converted to
+type ValueType int
+
+const (
+	// TypeAuto parse the value into its natural YAML scalar type (int, float, bool or null)
+	TypeAuto ValueType = iota
+	TypeString
+	TypeInt
+	TypeFloat
+	TypeBool
+	TypeDuration
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case TypeAuto:
+		return "auto"
+	case TypeString:
+		return "string"
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeBool:
+		return "bool"
+	case TypeDuration:
+		return "duration"
+	}
+	return fmt.Sprintf("ValueType(%d)", int(t))
+}
+
+// sources of configurations used in Origin
+const (
+	SourceDefault   = "default"
+	SourceConfigMap = "config map"
+	SourceFile      = "file"
+	SourceReader    = "reader"
+	SourceEnv       = "env"
+	SourceFlag      = "flag"
+	SourceSet       = "set"
+)
+
+// Origin describe where the value of a key comes from
+type Origin struct {
+	// Source is the kind of source, one of the Source constants
+	Source string
+	// Path is the file path for files, the format for readers,
+	// the env variable name for env and the flag name for flags
+	Path string
+	// Line where the key is defined, only for YAML files
+	Line int
+	// Env has the env variables used to replace the placeholders in the value
+	Env string
+}
+
 type Configuration interface {
 	SetDefaults() ConfigMap
 }
 
+// Config is safe for concurrent use through its methods,
+// ConfigMap and EnvConfigMap should not be modified directly once shared.
+// the values written in ConfigMap before the configs are loaded are kept as
+// base, later writes are replaced by the next load, use Set instead
 type Config struct {
+	mu           sync.RWMutex
 	ConfigMap    ConfigMap
 	EnvConfigMap ConfigMap
 	configImpl   Configuration
+	// layers of configurations, merged in order of precedence
+	implDefaults ConfigMap
+	defaults     ConfigMap
+	base         ConfigMap
+	layers       []layer
+	flagSets     []FlagValueSet
+	// builtFlags has the values of the flags used by the last build
+	builtFlags []override
+	overrides  []override
+	origins    map[string]Origin
+	envTypes   *placeholderTypes
+	// withEnv is true once WithEnv is called, until then the
+	// placeholders are replaced with the variables of the process
+	withEnv       bool
+	envPrefix     string
+	envReplacer   *strings.Replacer
+	envBindings   map[string][]string
+	onChange      []func(old, new ConfigMap)
+	onReloadError []func(err error)
+	decodeHooks   []mapstructure.DecodeHookFunc
+	validation    bool
+	mergeOptions  MergeOptions
+	profile       string
+	configPaths   []string
+	configName    string
+	// built is true after the first build
+	built bool
 }
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator is implemented by configuration structs that need to run
+// their own validation after Unmarshal
+type Validator interface {
+	Validate() error
+}
+
+// FieldError is a validation error for a key
+type FieldError struct {
+	// Key in `dot-notation` using the mapstructure names
+	Key string
+	// Rule that failed, empty for errors from a Validator
+	Rule string
+	Err  error
+}
+
+func (e FieldError) Error() string {
+	if e.Key == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Key, e.Err)
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError has all the validation errors found
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		msgs[i] = fieldErr.Error()
+	}
+	return "invalid configurations:\n" + strings.Join(msgs, "\n")
+}
+
+// Validate check the struct given using the `validate` struct tags, and calling
+// Validate on every struct implementing Validator. supported rules, separated by commas:
+//
+//	required    the value should not be the zero value
+//	omitempty   skip the other rules if the value is the zero value
+//	min=N       minimum value for numbers, or minimum length for strings, slices and maps
+//	max=N       maximum value for numbers, or maximum length for strings, slices and maps
+//	oneof=a b   the value should be one of the values separated by spaces
+//	url         the value should be an absolute URL
+//	hostname    the value should be a valid hostname (RFC 1123)
+//
+// all the errors are returned in a *ValidationError using the mapstructure keys
+func Validate(s any) error {
+	return validate(s, "")
+}
+
+// validate check the struct given adding the prefix to the keys of the errors
+func validate(s any, prefix string) error {
+	v := &validation{}
+	v.validate(reflect.ValueOf(s), prefix)
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+type validation struct {
+	errors []FieldError
+}
+
+func (v *validation) add(key, rule string, err error) {
+	v.errors = append(v.errors, FieldError{Key: key, Rule: rule, Err: err})
+}
+
+func (v *validation) validate(val reflect.Value, key string) {
+	for val.Kind() == reflect.Pointer || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return
+		}
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Struct:
+		v.validateStruct(val, key)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			v.validate(val.Index(i), joinKey(key, strconv.Itoa(i)))
+		}
+	case reflect.Map:
+		// the keys are sorted, so the errors are always in the same order
+		names := make(map[string]reflect.Value, val.Len())
+		iter := val.MapRange()
+		for iter.Next() {
+			names[fmt.Sprint(iter.Key().Interface())] = iter.Key()
+		}
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			v.validate(val.MapIndex(names[name]), joinKey(key, name))
+		}
+	}
+}
+
+func (v *validation) validateStruct(val reflect.Value, key string) {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, squash := fieldKey(field)
+		if name == "-" {
+			continue
+		}
+		fieldKey := key
+		if !squash {
+			fieldKey = joinKey(key, name)
+		}
+		fieldVal := val.Field(i)
+		if tag, ok := field.Tag.Lookup("validate"); ok {
+			v.validateRules(fieldVal, fieldKey, tag)
+		}
+		v.validate(fieldVal, fieldKey)
+	}
+	if validator, ok := asValidator(val); ok {
+		if err := validator.Validate(); err != nil {
+			v.add(key, "", err)
+		}
+	}
+}
+
+// asValidator return the Validator implemented by the value or by a pointer to it
+func asValidator(val reflect.Value) (Validator, bool) {
+	if val.CanAddr() {
+		if validator, ok := val.Addr().Interface().(Validator); ok {
+			return validator, true
+		}
+	}
+	validator, ok := val.Interface().(Validator)
+	return validator, ok
+}
+
+func (v *validation) validateRules(val reflect.Value, key, tag string) {
+	rules := strings.Split(tag, ",")
+	for _, rule := range rules {
+		if rule == "omitempty" && val.IsZero() {
+			return
+		}
+	}
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if err := checkRule(val, name, arg); err != nil {
+			v.add(key, name, err)
+			if name == "required" {
+				// the other rules of a missing key are not checked, so it is listed once
+				return
+			}
+		}
+	}
+}
+
+func checkRule(val reflect.Value, rule, arg string) error {
+	switch rule {
+	case "", "omitempty":
+		return nil
+	case "required":
+		if val.IsZero() {
+			return errors.New("is required")
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q", rule, arg)
+		}
+		size, isLength, ok := sizeOf(val)
+		if !ok {
+			return fmt.Errorf("rule %s is not supported for %s", rule, val.Kind())
+		}
+		what := "value"
+		if isLength {
+			what = "length"
+		}
+		if rule == "min" && size < limit {
+			return fmt.Errorf("%s should be at least %s", what, arg)
+		}
+		if rule == "max" && size > limit {
+			return fmt.Errorf("%s should be at most %s", what, arg)
+		}
+	case "oneof":
+		value := fmt.Sprint(indirect(val).Interface())
+		for _, option := range strings.Fields(arg) {
+			if value == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("should be one of [%s]", strings.Join(strings.Fields(arg), " "))
+	case "url":
+		s, ok := stringOf(val)
+		if !ok {
+			return fmt.Errorf("rule url is not supported for %s", val.Kind())
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%q is not a valid url", s)
+		}
+	case "hostname":
+		s, ok := stringOf(val)
+		if !ok {
+			return fmt.Errorf("rule hostname is not supported for %s", val.Kind())
+		}
+		if !isHostname(s) {
+			return fmt.Errorf("%q is not a valid hostname", s)
+		}
+	default:
+		return fmt.Errorf("unknown validation rule %q", rule)
+	}
+	return nil
+}
+
+// sizeOf return the value for numbers or the length for strings, slices and maps
+func sizeOf(val reflect.Value) (size float64, isLength bool, ok bool) {
+	val = indirect(val)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(val.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), false, true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(val.String())), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(val.Len()), true, true
+	}
+	return 0, false, false
+}
+
+func stringOf(val reflect.Value) (string, bool) {
+	val = indirect(val)
+	if val.Kind() != reflect.String {
+		return "", false
+	}
+	return val.String(), true
+}
+
+// indirect return the value pointed, or a zero value for nil pointers
+func indirect(val reflect.Value) reflect.Value {
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return reflect.Zero(val.Type().Elem())
+		}
+		val = val.Elem()
+	}
+	return val
+}
+
+// isHostname validate a hostname following RFC 1123
+func isHostname(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fieldKey return the key of the field from the mapstructure tag,
+// and if the field is squashed into the parent struct
+func fieldKey(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("mapstructure")
+	name, opts, _ := strings.Cut(tag, ",")
+	squash := strings.Contains(","+opts+",", ",squash,") || (field.Anonymous && name == "" && tag != "")
+	if name == "" {
+		name = field.Name
+	}
+	return name, squash
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
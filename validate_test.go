@@ -0,0 +1,119 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validateServer struct {
+	Host  string `mapstructure:"host" validate:"required,hostname"`
+	Port  int    `mapstructure:"port" validate:"min=1,max=65535"`
+	Proxy string `mapstructure:"proxy" validate:"omitempty,url"`
+}
+
+type validateConfig struct {
+	Stage   string           `mapstructure:"stage" validate:"required,oneof=dev prod"`
+	Server  validateServer   `mapstructure:"server"`
+	Backups []validateServer `mapstructure:"backups" validate:"max=2"`
+	Tags    []string         `mapstructure:"tags" validate:"omitempty,min=1"`
+	Workers *int             `mapstructure:"workers" validate:"omitempty,min=1"`
+}
+
+// Validate check that a dev stage does not use the standard port
+func (c *validateConfig) Validate() error {
+	if c.Stage == "dev" && c.Server.Port == 80 {
+		return errors.New("dev stage should not use port 80")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("valid struct should return no errors", func(t *testing.T) {
+		workers := 4
+		cfg := &validateConfig{
+			Stage:   "prod",
+			Server:  validateServer{Host: "api.example.com", Port: 443, Proxy: "http://proxy:3128"},
+			Backups: []validateServer{{Host: "localhost", Port: 8080}},
+			Workers: &workers,
+		}
+		assert.NoError(t, Validate(cfg))
+	})
+
+	t.Run("all failing keys should be returned", func(t *testing.T) {
+		workers := 0
+		cfg := &validateConfig{
+			Stage:   "test",
+			Server:  validateServer{Port: 70000, Proxy: "not a url"},
+			Backups: []validateServer{{Host: "-bad-", Port: 80}},
+			Workers: &workers,
+		}
+		err := Validate(cfg)
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		keys := make([]string, 0)
+		for _, fieldErr := range validationErr.Errors {
+			keys = append(keys, fieldErr.Key+" "+fieldErr.Rule)
+		}
+		assert.Equal(t, []string{
+			"stage oneof",
+			// the other rules of the keys required are not checked
+			"server.host required",
+			"server.port max",
+			"server.proxy url",
+			"backups.0.host hostname",
+			"workers min",
+		}, keys)
+		assert.Contains(t, err.Error(), "server.port: value should be at most 65535")
+	})
+
+	t.Run("validate hook should be called", func(t *testing.T) {
+		cfg := &validateConfig{Stage: "dev", Server: validateServer{Host: "localhost", Port: 80}}
+		err := Validate(cfg)
+		assert.EqualError(t, err, "invalid configurations:\ndev stage should not use port 80")
+	})
+
+	t.Run("map errors should be sorted by key", func(t *testing.T) {
+		servers := make(map[string]validateServer)
+		for _, name := range []string{"e", "b", "d", "a", "c"} {
+			servers[name] = validateServer{Host: "localhost"}
+		}
+		err := Validate(&struct {
+			Servers map[string]validateServer `mapstructure:"servers"`
+		}{Servers: servers})
+		assert.EqualError(t, err, "invalid configurations:\n"+
+			"servers.a.port: value should be at least 1\n"+
+			"servers.b.port: value should be at least 1\n"+
+			"servers.c.port: value should be at least 1\n"+
+			"servers.d.port: value should be at least 1\n"+
+			"servers.e.port: value should be at least 1")
+	})
+
+	t.Run("unknown rules should return an error", func(t *testing.T) {
+		err := Validate(&struct {
+			Name string `mapstructure:"name" validate:"required,email"`
+		}{Name: "a"})
+		assert.EqualError(t, err, "invalid configurations:\nname: unknown validation rule \"email\"")
+	})
+}
+
+func TestUnmarshalValidate(t *testing.T) {
+	cm := ConfigMap{
+		"stage":  "dev",
+		"server": ConfigMap{"host": "localhost", "port": 0},
+	}
+	// the validation is disabled by default
+	assert.NoError(t, New().SetConfigMap(cm).Unmarshal(&validateConfig{}))
+
+	config := New().WithValidation()
+	config.SetConfigMap(cm)
+	cfg := &validateConfig{}
+	err := config.Unmarshal(cfg)
+	assert.EqualError(t, err, "invalid configurations:\nserver.port: value should be at least 1")
+	assert.Equal(t, "localhost", cfg.Server.Host)
+
+	config.Set("server.port", 8080)
+	assert.NoError(t, config.Unmarshal(cfg))
+	assert.Equal(t, 8080, cfg.Server.Port)
+}
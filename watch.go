@@ -0,0 +1,175 @@
+package config
+
+import (
+	"crypto/sha256"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// defaultWatchInterval is the interval used by WatchConfig when the interval given is not positive
+const defaultWatchInterval = time.Second
+
+// fileState is the information used to detect if a config file changed on disk,
+// the content is compared, as the size and time can be the same after a change
+type fileState struct {
+	exists bool
+	hash   [sha256.Size]byte
+}
+
+// OnChange register a callback that is called every time the configurations
+// are reloaded by WatchConfig and the resulting ConfigMap is different
+// the ConfigMaps received are snapshots and should not be modified
+func (c *Config) OnChange(fn func(old, new ConfigMap)) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = append(c.onChange, fn)
+	return c
+}
+
+// OnReloadError register a callback that is called when WatchConfig is not able
+// to reload the configurations, in that case the previous ConfigMap is kept
+func (c *Config) OnReloadError(fn func(err error)) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onReloadError = append(c.onReloadError, fn)
+	return c
+}
+
+// WatchConfig start polling the files given to LoadConfigs and LoadFS, and the files
+// they include, every interval. when the content of any of them change, and it is the
+// same on the next poll, all the sources are loaded again and
+// the configurations are built from scratch, then the OnChange callbacks are called.
+// polling is used so it works in any filesystem without extra dependencies.
+// intervals not positive are replaced by one second.
+// the returned function stops the watcher and waits until it finish
+func (c *Config) WatchConfig(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	sources := c.watchedSources()
+	states := statSources(sources)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		// pending has the states changed, they are reloaded once they are the same
+		// on the next poll, so the files being written are not loaded half written
+		var pending []fileState
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sources = c.watchedSources()
+				current := statSources(sources)
+				if reflect.DeepEqual(states, current) {
+					pending = nil
+					continue
+				}
+				if !reflect.DeepEqual(pending, current) {
+					pending = current
+					continue
+				}
+				pending = nil
+				err := c.reload()
+				states = current
+				// the files included can change on reload
+				if reloaded := c.watchedSources(); !reflect.DeepEqual(reloaded, sources) {
+					states = statSources(reloaded)
+				}
+				if err != nil {
+					c.mu.RLock()
+					callbacks := c.onReloadError
+					c.mu.RUnlock()
+					for _, fn := range callbacks {
+						fn(err)
+					}
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// reload read again all the sources loaded and build the configurations,
+// the current ones are replaced only if the load succeed
+func (c *Config) reload() error {
+	// the sources are read and SetDefaults is called without the lock,
+	// so the configurations can be read meanwhile
+	c.mu.RLock()
+	sources := make([]configSource, len(c.layers))
+	for i, l := range c.layers {
+		sources[i] = l.source
+	}
+	impl := c.configImpl
+	c.mu.RUnlock()
+	layers, err := readSources(sources)
+	if err != nil {
+		return err
+	}
+	implDefaults := readImplDefaults(impl)
+
+	c.mu.Lock()
+	// the layers loaded meanwhile are kept, the ones read again are replaced
+	next := make([]layer, len(c.layers))
+	for i, l := range c.layers {
+		next[i] = l
+		for j, source := range sources {
+			if l.source.equal(source) {
+				next[i] = layers[j]
+				break
+			}
+		}
+	}
+	old := c.ConfigMap
+	prevLayers, prevImplDefaults := c.layers, c.implDefaults
+	c.layers, c.implDefaults = next, implDefaults
+	if err := c.build(); err != nil {
+		c.layers, c.implDefaults = prevLayers, prevImplDefaults
+		c.mu.Unlock()
+		return err
+	}
+	current := c.ConfigMap
+	callbacks := c.onChange
+	c.mu.Unlock()
+
+	if reflect.DeepEqual(old, current) {
+		return nil
+	}
+	for _, fn := range callbacks {
+		fn(old, current)
+	}
+	return nil
+}
+
+// watchedSources return the sources loaded and the files they include
+func (c *Config) watchedSources() []configSource {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var sources []configSource
+	for _, l := range c.layers {
+		sources = append(sources, l.watched()...)
+	}
+	return sources
+}
+
+// statSources return the current state of the sources given
+func statSources(sources []configSource) []fileState {
+	states := make([]fileState, len(sources))
+	for i, source := range sources {
+		states[i] = source.stat()
+	}
+	return states
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchConfig(t *testing.T) {
+	t.Run("test reload configs when file change", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "config.yaml")
+		assert.NoError(t, os.WriteFile(file, []byte("app:\n  port: 3001\n"), 0644))
+
+		changes := make(chan [2]ConfigMap, 1)
+		config := New().OnChange(func(old, new ConfigMap) {
+			changes <- [2]ConfigMap{old, new}
+		})
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.Equal(t, 3001, config.Get("app.port"))
+
+		stop := config.WatchConfig(10 * time.Millisecond)
+		defer stop()
+
+		assert.NoError(t, os.WriteFile(file, []byte("app:\n  port: 4000\n  host: localhost\n"), 0644))
+
+		select {
+		case change := <-changes:
+			assert.Equal(t, 3001, GetValue(change[0], []string{"app", "port"}))
+			assert.Equal(t, 4000, GetValue(change[1], []string{"app", "port"}))
+		case <-time.After(2 * time.Second):
+			t.Fatal("OnChange was not called after the file changed")
+		}
+		stop()
+		assert.Equal(t, 4000, config.Get("app.port"))
+		assert.Equal(t, "localhost", config.Get("app.host"))
+	})
+
+	t.Run("test keep previous configs when reload fail", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "config.json")
+		assert.NoError(t, os.WriteFile(file, []byte(`{"app": {"port": 3001}}`), 0644))
+
+		errs := make(chan error, 1)
+		config := New().OnReloadError(func(err error) {
+			errs <- err
+		})
+		assert.NoError(t, config.LoadConfigs(file))
+
+		stop := config.WatchConfig(10 * time.Millisecond)
+		defer stop()
+
+		assert.NoError(t, os.WriteFile(file, []byte(`{"app": `), 0644))
+
+		select {
+		case err := <-errs:
+			assert.ErrorContains(t, err, "fail to load configs")
+		case <-time.After(2 * time.Second):
+			t.Fatal(errors.New("OnReloadError was not called after an invalid change"))
+		}
+		stop()
+		assert.Equal(t, float64(3001), config.Get("app.port"))
+	})
+
+	t.Run("test keep defaults after reload", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "config.yaml")
+		assert.NoError(t, os.WriteFile(file, []byte("a: 1\n"), 0644))
+
+		changes := make(chan ConfigMap, 1)
+		config := New().SetConfigImpl(&MockConfig{}).OnChange(func(_, new ConfigMap) {
+			changes <- new
+		})
+		assert.NoError(t, config.LoadConfigs(file))
+
+		stop := config.WatchConfig(10 * time.Millisecond)
+		defer stop()
+
+		assert.NoError(t, os.WriteFile(file, []byte("a: 2\nb: 3\n"), 0644))
+
+		select {
+		case cm := <-changes:
+			assert.Equal(t, 2, cm["a"])
+			assert.Equal(t, 2, GetValue(cm, []string{"this", "is", "a", "very", "nested", "config", "with", "second"}))
+		case <-time.After(2 * time.Second):
+			t.Fatal("OnChange was not called after the file changed")
+		}
+	})
+
+	t.Run("test SetDefaults can read the configs", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "config.yaml")
+		assert.NoError(t, os.WriteFile(file, []byte("a: 1\n"), 0644))
+
+		changes := make(chan ConfigMap, 1)
+		config := New().OnChange(func(_, new ConfigMap) {
+			changes <- new
+		})
+		config.SetConfigImpl(&readingConfig{config: config})
+		assert.NoError(t, config.LoadConfigs(file))
+		assert.Nil(t, config.Get("b"))
+
+		stop := config.WatchConfig(10 * time.Millisecond)
+		defer stop()
+
+		assert.NoError(t, os.WriteFile(file, []byte("a: 2\n"), 0644))
+
+		select {
+		case cm := <-changes:
+			assert.Equal(t, 2, cm["a"])
+			assert.Equal(t, 1, cm["b"])
+		case <-time.After(2 * time.Second):
+			t.Fatal("OnChange was not called after the file changed")
+		}
+	})
+
+	t.Run("test reload changes with the same size and time", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "config.yaml")
+		assert.NoError(t, os.WriteFile(file, []byte("a: 1\n"), 0644))
+		info, err := os.Stat(file)
+		assert.NoError(t, err)
+
+		changes := make(chan ConfigMap, 1)
+		config := New().OnChange(func(_, new ConfigMap) {
+			changes <- new
+		})
+		assert.NoError(t, config.LoadConfigs(file))
+		stop := config.WatchConfig(10 * time.Millisecond)
+		defer stop()
+
+		assert.NoError(t, os.WriteFile(file, []byte("a: 2\n"), 0644))
+		assert.NoError(t, os.Chtimes(file, info.ModTime(), info.ModTime()))
+
+		select {
+		case cm := <-changes:
+			assert.Equal(t, 2, cm["a"])
+		case <-time.After(2 * time.Second):
+			t.Fatal("OnChange was not called after the file changed")
+		}
+	})
+
+	t.Run("test intervals not positive don't panic", func(t *testing.T) {
+		config := New()
+		for _, interval := range []time.Duration{0, -time.Second} {
+			stop := config.WatchConfig(interval)
+			stop()
+		}
+	})
+}
+
+// readingConfig set as default of b the value of a before the configs are built
+type readingConfig struct {
+	config *Config
+}
+
+func (rc *readingConfig) SetDefaults() ConfigMap {
+	return ConfigMap{"b": rc.config.Get("a")}
+}